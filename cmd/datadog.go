@@ -1,14 +1,9 @@
 package cmd
 
 import (
-	"log"
-	"os"
-	"sync"
-	"time"
+	"errors"
 
-	"github.com/rs/jplot/data"
 	"github.com/rs/jplot/source"
-	"github.com/rs/jplot/window"
 	"github.com/spf13/cobra"
 )
 
@@ -28,7 +23,7 @@ Example:
     jplot datadog --apiKey 123412341234123412341234 --appKey 123412341234123412341234 mem.heap+mem.sys+mem.stack counter:cpu.sTime+cpu.uTime threads
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		runDatadog(args)
+		exit(runDatadog(args))
 	},
 }
 
@@ -51,86 +46,20 @@ func init() {
 	datadogCmd.Flags().StringVar(&datadogBaseUrl, "baseUrl", "", "Datadog Base URL (Defaults to https://app.datadoghq.com)")
 }
 
-func runDatadog(args []string) {
+func runDatadog(args []string) error {
 	specs := parseSpec(args)
-
-	ds := &data.DataSet{
-		Size:              NumberPoints,
-		ExpectedFrequency: time.Second,
-	}
-	ready := NewAtomicReady(false)
-	wg := &sync.WaitGroup{}
-	wg.Add(1)
-	defer wg.Wait()
-	exit := make(chan struct{})
-	defer close(exit)
-	go func() {
-		defer wg.Done()
-		window.Clear()
-		t := time.NewTicker(time.Second)
-		defer t.Stop()
-		for {
-			width, height, err := window.Size()
-			if err != nil {
-				log.Fatal("Cannot get window size")
-			}
-			select {
-			case <-t.C:
-				if ready.Ready() {
-					window.Render(specs, ds, width, height-25)
-				}
-			case <-exit:
-				if ready.Ready() {
-					window.Render(specs, ds, width, height-25)
-				}
-				return
-			}
-		}
-	}()
-
 	if datadogApiKey == "" {
-		log.Fatal("Invalid api key specified")
-		os.Exit(1)
+		return errors.New("invalid api key specified")
 	}
-	s := source.NewDatadog(datadogApiKey, datadogApplicationKey, datadogBaseUrl, specs, time.Second*10)
-	defer s.Close()
-
-	readyMap := make(map[string]bool, 0)
-	for _, gs := range specs {
-		for _, f := range gs.Fields {
-			readyMap[f.ID] = false
-		}
-	}
-
-	for {
-		result, err := s.Get()
-		if err != nil {
-			log.Fatalf("Input error: %v", err)
-		}
-		if result == nil {
-			break
-		}
-		for _, gs := range specs {
-			for _, f := range gs.Fields {
+	raiseDatadogInterval()
+	return run(source.NewDatadog(datadogApiKey, datadogApplicationKey, datadogBaseUrl, specs, interval, timeout), specs)
+}
 
-				dataPoints, ok := result.DataPoints[f.ID]
-				if !ok {
-					log.Fatalf("Cannot get %s: %v", f.Name, err)
-				}
-				if len(dataPoints) > 0 {
-					ds.PushPoints(f.ID, dataPoints, f.Counter)
-					readyMap[f.ID] = true
-				}
-			}
-		}
-		if !ready.Ready() {
-			// check if ready
-			for _, v := range readyMap {
-				if !v {
-					continue
-				}
-			}
-			ready.MarkReady()
-		}
+// raiseDatadogInterval raises --interval to the minimum interval of Datadog
+// queries, so the graphs are rendered and the points expected at the pace
+// they are actually fetched.
+func raiseDatadogInterval() {
+	if interval < source.DatadogMinInterval {
+		interval = source.DatadogMinInterval
 	}
 }
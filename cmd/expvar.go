@@ -1,17 +1,13 @@
 package cmd
 
 import (
-	"log"
-	"sync"
-	"time"
+	"errors"
 
-	"github.com/rs/jplot/data"
 	"github.com/rs/jplot/source"
-	"github.com/rs/jplot/window"
 	"github.com/spf13/cobra"
 )
 
-var url string
+var urls []string
 
 // expvarCmd represents the expvar command
 var expvarCmd = &cobra.Command{
@@ -22,70 +18,38 @@ var expvarCmd = &cobra.Command{
 Example: (Using the example producer in doc/)
 
     jplot expvar --url http://:8080/debug/vars mem.heap+mem.sys+mem.stack counter:cpu.sTime+cpu.uTime threads
+
+Several endpoints can be graphed together by naming them with --url name=url.
+Their fields are then qualified with the name of their endpoint:
+
+    jplot expvar --url a=http://a:8080/debug/vars --url b=http://b:8080/debug/vars a:mem.heap+b:mem.heap
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		runExpvar(args)
+		exit(runExpvar(args))
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(expvarCmd)
 
-	expvarCmd.Flags().StringVar(&url, "url", "", "URL to fetch every second")
+	expvarCmd.Flags().StringArrayVar(&urls, "url", nil, "URL to fetch every interval, as [name=]url, can be repeated")
 	expvarCmd.MarkFlagRequired("url")
 }
 
-func runExpvar(args []string) {
-	specs := parseSpec(args)
-
-	dp := &data.DataSet{
-		Size:              NumberPoints,
-		ExpectedFrequency: time.Second,
+func runExpvar(args []string) error {
+	if len(urls) == 0 {
+		return errors.New("invalid URL")
 	}
-	wg := &sync.WaitGroup{}
-	wg.Add(1)
-	defer wg.Wait()
-	exit := make(chan struct{})
-	defer close(exit)
-	go func() {
-		defer wg.Done()
-		window.Clear()
-		t := time.NewTicker(time.Second)
-		defer t.Stop()
-		for {
-			width, height, err := window.Size()
-			if err != nil {
-				log.Fatal("Cannot get window size")
-			}
-			select {
-			case <-t.C:
-				window.Render(specs, dp, width, height-25)
-			case <-exit:
-				window.Render(specs, dp, width, height-25)
-				return
-			}
-		}
-	}()
-
-	if url == "" {
-		log.Fatal("invalid URL")
+	uris, err := parseSourceURIs(urls)
+	if err != nil {
+		return err
 	}
-
-	s := source.NewHTTP(url, time.Second)
-	defer s.Close()
-	for {
-		result, err := s.Get()
-		if err != nil {
-			log.Fatalf("Input error: %v", err)
-		}
-		for _, gs := range specs {
-			for _, f := range gs.Fields {
-				v, ok := result.DataPoints[f.Name]
-				if !ok {
-					log.Fatalf("Cannot get %s: %v\n\t%v", f.Name, err, result.DataPoints)
-				}
-				dp.PushPoints(f.ID, v, f.Counter)
-			}
-		}
+	specs := parseSpec(args)
+	s, err := openSources(uris, specs, func(url string, opts source.Options) (source.Getter, error) {
+		return source.NewHTTP(url, opts), nil
+	})
+	if err != nil {
+		return err
 	}
+	return run(s, specs)
 }
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/rs/jplot/source"
+	"github.com/spf13/cobra"
+)
+
+var prometheusURLs []string
+
+// prometheusCmd represents the prometheus command
+var prometheusCmd = &cobra.Command{
+	Use:   "prometheus",
+	Short: "Graph using a Prometheus metrics endpoint",
+	Long: `Graph using a Prometheus metrics endpoint
+
+Series are referenced by their metric name followed by their labels sorted by
+name. Counters, as well as histogram and summary sums and counts, are graphed
+as with the counter: option unless the gauge: option is given.
+
+Example:
+
+    jplot prometheus --url http://:9090/metrics 'http_requests_total{code="200"}+http_requests_total{code="500"}' go_goroutines
+
+Several endpoints can be graphed together by naming them with --url name=url.
+Their series are then qualified with the name of their endpoint:
+
+    jplot prometheus --url a=http://a:9090/metrics --url b=http://b:9090/metrics a:go_goroutines+b:go_goroutines
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		exit(runPrometheus(args))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(prometheusCmd)
+
+	prometheusCmd.Flags().StringArrayVar(&prometheusURLs, "url", nil, "URL to fetch every interval, as [name=]url, can be repeated")
+	prometheusCmd.MarkFlagRequired("url")
+}
+
+func runPrometheus(args []string) error {
+	if len(prometheusURLs) == 0 {
+		return errors.New("invalid URL")
+	}
+	uris, err := parseSourceURIs(prometheusURLs)
+	if err != nil {
+		return err
+	}
+	specs := parseSpec(args)
+	s, err := openSources(uris, specs, func(url string, opts source.Options) (source.Getter, error) {
+		return source.NewPrometheus(url, opts), nil
+	})
+	if err != nil {
+		return err
+	}
+	return run(s, specs)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rs/jplot/source"
+	"github.com/spf13/cobra"
+)
+
+var replaySpeed string
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay file spec...",
+	Short: "Graph a session recorded with --record",
+	Long: `Graph a session recorded with --record
+
+The recorded values are replayed at the pace they were recorded, or faster with
+--speed. Use --speed instant to replay them all at once and render the last
+frame.
+
+Example:
+
+    jplot expvar --url http://:8080/debug/vars --record session.jsonl mem.heap+mem.sys+mem.stack threads
+    jplot replay --speed 10x session.jsonl mem.heap+mem.sys+mem.stack threads
+`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		exit(runReplay(args[0], args[1:]))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().StringVar(&replaySpeed, "speed", "1x", "Replay speed, like 10x, or instant")
+}
+
+// parseSpeed parses a replay speed like 10x, or instant which returns 0.
+func parseSpeed(s string) (float64, error) {
+	if s == "instant" {
+		return 0, nil
+	}
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("invalid speed: %s", s)
+	}
+	return speed, nil
+}
+
+func runReplay(file string, args []string) error {
+	names, err := source.RecordedSources(file)
+	if err != nil {
+		return fmt.Errorf("cannot replay: %v", err)
+	}
+	for _, name := range names {
+		// fields of the recorded named sources, like a:mem.heap
+		sourceNames[name] = true
+	}
+	specs := parseSpec(args)
+	speed, err := parseSpeed(replaySpeed)
+	if err != nil {
+		return err
+	}
+	r, err := source.NewReplay(file, speed)
+	if err != nil {
+		return fmt.Errorf("cannot replay: %v", err)
+	}
+	return run(r, specs)
+}
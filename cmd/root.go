@@ -1,34 +1,104 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/rs/jplot/data"
+	"github.com/rs/jplot/pipeline"
+	"github.com/rs/jplot/source"
+	"github.com/rs/jplot/web"
+	"github.com/rs/jplot/window"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"io/ioutil"
 	"log"
+	"net"
+	"net/http"
+	"os/signal"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 var cfgFile string
 var NumberPoints int
+var TimeWindow time.Duration
+var RetentionTiers []data.Tier
+var tiers string
+var MaxSeries int
+var MaxFailures int
+var windowWidth, windowHeight int
+var cellWidth, cellHeight int
+var protocol string
+var recordFile string
+var outputDir, outputFormat string
+var snapshotEvery, onceDuration time.Duration
+var serveAddr string
+var exportFile string
+var interval, timeout time.Duration
+var headers []string
+var basicAuth string
+var caFile, certFile, keyFile string
+var insecure bool
+
+// httpOptions holds the headers, credentials and TLS configuration of HTTP
+// sources.
+var httpOptions source.Options
+var sourceURIs []string
+var renderer window.Renderer = window.ImageRenderer{}
+
+// stopSignal holds the signal which stopped the run loop, if any.
+var stopSignal atomic.Value
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "jplot",
-	Short: "A brief description of your application",
-	Long: `A longer description that spans multiple lines and likely contains
-examples and usage of using your application. For example:
+	Short: "Graph values from one or more sources in the terminal",
+	Long: `Graph values from one or more sources in the terminal
+
+The sources are given as URIs with --source, and their values are graphed
+according to the specs given as arguments. Several sources can be mixed.
+
+Example:
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	// Uncomment the following line if your bare application
-	// has an action associated with it:
-	//	Run: func(cmd *cobra.Command, args []string) { },
+    jplot --source http://:8080/debug/vars --source exec:'./stats.sh' mem.heap+mem.sys load
+`,
+	Args: cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(sourceURIs) == 0 {
+			cmd.Help()
+			return
+		}
+		exit(runSources(args))
+	},
+}
+
+// runSources graphs the sources given with --source.
+func runSources(args []string) error {
+	uris, err := parseSourceURIs(sourceURIs)
+	if err != nil {
+		return err
+	}
+	datadogOnly := true
+	for _, u := range uris {
+		datadogOnly = datadogOnly && strings.HasPrefix(u.uri, "datadog:")
+	}
+	if datadogOnly {
+		raiseDatadogInterval()
+	}
+	specs := parseSpec(args)
+	s, err := openSources(uris, specs, source.Open)
+	if err != nil {
+		return err
+	}
+	return run(s, specs)
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -41,7 +111,7 @@ func Execute() {
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initConfig, initInterval, initHTTP, initRetention, initWindow, initExport)
 
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
@@ -49,11 +119,35 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.jplot2.yaml)")
 
 	// add common flags
+	rootCmd.PersistentFlags().DurationVar(&interval, "interval", time.Second, "Time between two fetches of polled sources and between two frames")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 5*time.Second, "Maximum duration of a fetch, after which it fails (0 for no limit)")
+	rootCmd.PersistentFlags().StringArrayVarP(&headers, "header", "H", nil, "Header to add to HTTP requests, like 'Authorization: Bearer token', can be repeated (the value can be env:VAR or file:path)")
+	rootCmd.PersistentFlags().StringVar(&basicAuth, "basic-auth", "", "Basic authentication of HTTP requests as user:password (the password can be env:VAR or file:path)")
+	rootCmd.PersistentFlags().StringVar(&caFile, "ca-cert", "", "PEM file of the certificate authorities to verify HTTPS servers with")
+	rootCmd.PersistentFlags().StringVar(&certFile, "cert", "", "PEM file of the client certificate presented to HTTPS servers (requires --key)")
+	rootCmd.PersistentFlags().StringVar(&keyFile, "key", "", "PEM file of the key of the client certificate")
+	rootCmd.PersistentFlags().BoolVar(&insecure, "insecure", false, "Do not verify the certificates of HTTPS servers")
 	rootCmd.PersistentFlags().IntVar(&NumberPoints, "points", 100, "Number of values to plot")
+	rootCmd.PersistentFlags().DurationVar(&TimeWindow, "window", 0, "Time span of values to plot, like 15m (overrides --points)")
+	rootCmd.PersistentFlags().StringVar(&tiers, "tiers", "", "Tiered retention for long running sessions, like raw:10m,10s:6h,1m:7d, or \"default\" for those values")
+	rootCmd.PersistentFlags().IntVar(&MaxSeries, "max-series", 20, "Maximum number of series a field with wildcards or label matchers can expand to (0 for no limit)")
+	rootCmd.PersistentFlags().IntVar(&MaxFailures, "max-failures", 5, "Number of consecutive failures of the source after which jplot exits (0 for no limit)")
+	rootCmd.PersistentFlags().IntVar(&windowWidth, "width", 0, "Width of the graphs in pixels (default is detected from the terminal)")
+	rootCmd.PersistentFlags().IntVar(&windowHeight, "height", 0, "Height of the graphs in pixels (default is detected from the terminal)")
+	rootCmd.PersistentFlags().IntVar(&cellWidth, "cell-width", window.DefaultCellWidth, "Width of a terminal cell in pixels, used when the terminal does not report its pixel size")
+	rootCmd.PersistentFlags().IntVar(&cellHeight, "cell-height", window.DefaultCellHeight, "Height of a terminal cell in pixels, used when the terminal does not report its pixel size")
+	rootCmd.PersistentFlags().StringVar(&protocol, "protocol", "auto", "Terminal graphics protocol: auto, iterm2, kitty, sixel or text")
+	rootCmd.PersistentFlags().StringVar(&recordFile, "record", "", "Append the fetched values to a file to replay them later with the replay command")
+	rootCmd.PersistentFlags().StringVar(&outputDir, "output", "", "Write the graphs to image files in this directory instead of the terminal")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output-format", "png", "Format of the image files written with --output: png or svg")
+	rootCmd.PersistentFlags().DurationVar(&snapshotEvery, "snapshot-every", time.Minute, "Time between two image files written with --output")
+	rootCmd.PersistentFlags().DurationVar(&onceDuration, "once", 0, "Collect values for this duration, write a single image file and exit (implies --output)")
+	rootCmd.PersistentFlags().StringVar(&serveAddr, "serve", "", "Serve the graphs over HTTP on this address, like :9090")
+	rootCmd.PersistentFlags().StringVar(&exportFile, "export", "", "Export the values to a .csv or .json file on exit, or when receiving SIGUSR1")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	rootCmd.Flags().StringArrayVar(&sourceURIs, "source", nil, "URI of a source to graph as [name=]uri, can be repeated to mix sources (schemes: "+strings.Join(source.Schemes(), ", ")+")")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -82,26 +176,339 @@ func initConfig() {
 	}
 }
 
+// initRetention parses the retention tiers.
+func initRetention() {
+	if tiers == "" {
+		return
+	}
+	t, err := data.ParseTiers(tiers)
+	if err != nil {
+		log.Fatal(err)
+	}
+	RetentionTiers = t
+}
+
+// initInterval checks the fetch interval and timeout.
+func initInterval() {
+	if interval <= 0 {
+		log.Fatalf("Invalid interval: %v", interval)
+	}
+	if timeout < 0 {
+		log.Fatalf("Invalid timeout: %v", timeout)
+	}
+}
+
+// initHTTP sets up the headers, credentials and TLS configuration of HTTP
+// sources.
+func initHTTP() {
+	for _, h := range headers {
+		i := strings.IndexByte(h, ':')
+		if i <= 0 {
+			log.Fatalf("Invalid header %q: expected name: value", h)
+		}
+		value, err := resolveSecret(strings.TrimSpace(h[i+1:]))
+		if err != nil {
+			log.Fatalf("Invalid header %s: %v", h[:i], err)
+		}
+		if httpOptions.Header == nil {
+			httpOptions.Header = http.Header{}
+		}
+		httpOptions.Header.Add(strings.TrimSpace(h[:i]), value)
+	}
+	if basicAuth != "" {
+		creds := strings.SplitN(basicAuth, ":", 2)
+		httpOptions.Username = creds[0]
+		if len(creds) == 2 {
+			password, err := resolveSecret(creds[1])
+			if err != nil {
+				log.Fatalf("Invalid basic auth password: %v", err)
+			}
+			httpOptions.Password = password
+		}
+	}
+	if caFile != "" || certFile != "" || keyFile != "" || insecure {
+		c, err := source.NewTLSConfig(caFile, certFile, keyFile, insecure)
+		if err != nil {
+			log.Fatalf("Invalid TLS configuration: %v", err)
+		}
+		httpOptions.TLSConfig = c
+	}
+}
+
+// initExport checks the format of the export file.
+func initExport() {
+	switch filepath.Ext(exportFile) {
+	case "", ".csv", ".json":
+	default:
+		log.Fatalf("Invalid export file: %s must end with .csv or .json", exportFile)
+	}
+}
+
+// initWindow sets up how the window size is obtained and how graphs are
+// printed.
+func initWindow() {
+	if onceDuration > 0 && outputDir == "" {
+		outputDir = "."
+	}
+	if outputDir != "" {
+		if outputFormat != "png" && outputFormat != "svg" {
+			log.Fatalf("Invalid output format: %s", outputFormat)
+		}
+		width, height := windowWidth, windowHeight
+		if width <= 0 {
+			width = window.DefaultSnapshotWidth
+		}
+		if height <= 0 {
+			height = window.DefaultSnapshotHeight
+		}
+		snapshots := &window.SnapshotRenderer{
+			Dir:    outputDir,
+			Format: outputFormat,
+			Width:  width,
+			Height: height,
+			Every:  snapshotEvery,
+		}
+		renderer = snapshots
+		window.Init()
+		if onceDuration > 0 {
+			renderer = onceRenderer{snapshots}
+		}
+		return
+	}
+	if protocol == "text" {
+		renderer = window.TextRenderer{}
+		if _, _, err := window.CellCount(); err != nil {
+			headless(err)
+		}
+		return
+	}
+	p, err := window.ProtocolByName(protocol)
+	if err != nil {
+		log.Fatal(err)
+	}
+	window.Output = p
+	window.Providers = window.DefaultProviders(cellWidth, cellHeight)
+	if windowWidth > 0 && windowHeight > 0 {
+		window.Providers = []window.SizeProvider{window.Fixed{Width: windowWidth, Height: windowHeight}}
+	} else if windowWidth > 0 || windowHeight > 0 {
+		log.Fatal("Both --width and --height must be provided")
+	}
+	if _, _, err := window.Size(); err != nil {
+		headless(err)
+	}
+}
+
+// headless renders nothing if the graphs are served with --serve, as there
+// is no terminal to draw them, or exits with err otherwise.
+func headless(err error) {
+	if serveAddr == "" {
+		log.Fatalf("Cannot get window size error=%v", err)
+	}
+	renderer = window.NopRenderer{}
+}
+
+// onceRenderer only renders the last frame, for --once.
+type onceRenderer struct {
+	*window.SnapshotRenderer
+}
+
+func (onceRenderer) Render(specs []data.GraphSpec, ds *data.DataSet) error {
+	return nil
+}
+
+// run graphs specs with the values fetched from s, until s is exhausted, a
+// stop signal is received, or the --once duration is over.
+func run(s source.Getter, specs []data.GraphSpec) error {
+	ctx, cancel := stopContext()
+	defer cancel()
+	if onceDuration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, onceDuration)
+		defer cancel()
+	}
+
+	ds := &data.DataSet{
+		Size:              NumberPoints,
+		Window:            TimeWindow,
+		Tiers:             RetentionTiers,
+		ExpectedFrequency: interval,
+	}
+	serveErrs := make(chan error, 1)
+	startServer(specs, ds, func(err error) {
+		serveErrs <- err
+		cancel()
+	})
+	startExport(specs, ds)
+
+	s = recordSource(s)
+	defer s.Close()
+	p := pipeline.New(s, specs, ds, renderer)
+	p.Interval = interval
+	p.MaxSeries = MaxSeries
+	p.MaxFailures = MaxFailures
+	p.Status = window.SetStatus
+	err := p.Run(ctx)
+	select {
+	case serr := <-serveErrs:
+		if err == nil {
+			err = fmt.Errorf("cannot serve: %v", serr)
+		}
+	default:
+	}
+	if xerr := exportData(specs, ds); xerr != nil && err == nil {
+		err = fmt.Errorf("cannot export: %v", xerr)
+	}
+	return err
+}
+
+// startServer serves the graphs of specs over HTTP if --serve is given. If
+// serving fails, stop is called with the error to end the session, like a
+// stop signal would, with a last frame and export.
+func startServer(specs []data.GraphSpec, ds *data.DataSet, stop func(error)) {
+	if serveAddr == "" {
+		return
+	}
+	l, err := net.Listen("tcp", serveAddr)
+	if err != nil {
+		log.Fatalf("Cannot serve: %v", err)
+	}
+	srv := web.NewServer(specs, ds)
+	srv.Interval = interval
+	go func() {
+		stop(http.Serve(l, srv))
+	}()
+}
+
+// startExport exports the values of ds to the --export file every time one
+// of the exportSignals is received. Failures are shown on the status line so
+// the session goes on.
+func startExport(specs []data.GraphSpec, ds *data.DataSet) {
+	if exportFile == "" || len(exportSignals) == 0 {
+		return
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, exportSignals...)
+	go func() {
+		var failed bool
+		for range c {
+			if err := exportData(specs, ds); err != nil {
+				window.SetStatus(fmt.Sprintf("Cannot export: %v", err))
+				failed = true
+			} else if failed {
+				window.SetStatus("")
+				failed = false
+			}
+		}
+	}()
+}
+
+// exportMu serializes the exports triggered by signals and the one on exit.
+var exportMu sync.Mutex
+
+// exportData writes the values of every field of specs to the --export file,
+// if any, as CSV or JSON depending on its extension. The file is written
+// next to it under a temporary name and then renamed, so readers never see
+// a partial export.
+func exportData(specs []data.GraphSpec, ds *data.DataSet) error {
+	if exportFile == "" {
+		return nil
+	}
+	exportMu.Lock()
+	defer exportMu.Unlock()
+	var ids []string
+	for _, gs := range specs {
+		for _, f := range gs.Fields {
+			fids, _ := ds.Series(f)
+			ids = append(ids, fids...)
+		}
+	}
+	f, err := ioutil.TempFile(filepath.Dir(exportFile), "."+filepath.Base(exportFile)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+	if filepath.Ext(exportFile) == ".json" {
+		err = ds.WriteJSON(f, ids)
+	} else {
+		err = ds.WriteCSV(f, ids)
+	}
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), exportFile)
+}
+
+// stopContext returns a context canceled when SIGINT or SIGTERM is received,
+// so the run loop can stop fetching values and render a last frame. A second
+// signal kills jplot right away.
+func stopContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-c:
+			stopSignal.Store(sig)
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(c)
+	}()
+	return ctx, cancel
+}
+
+// exit terminates jplot once the run loop returned err: with status 1 on
+// error, 128 plus the signal number when stopped by a signal, or 0 when the
+// source is exhausted.
+func exit(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+	if sig, ok := stopSignal.Load().(syscall.Signal); ok {
+		os.Exit(128 + int(sig))
+	}
+}
+
 func parseSpec(args []string) []data.GraphSpec {
 	specs := make([]data.GraphSpec, 0, len(args))
 	for i, v := range args {
 		gs := data.GraphSpec{}
-		for j, name := range strings.Split(v, "+") {
+		for j, name := range splitFields(v) {
 			var isCounter bool
+			var isRate bool
 			var isMarker bool
-			n := strings.Split(name, ":")
+			var isGauge bool
+			var downsample string
+			n := splitSpec(name, ':')
+		options:
 			for len(n) > 1 {
 				switch n[0] {
 				case "counter":
 					isCounter = true
+				case "rate":
+					isRate = true
+				case "gauge":
+					isGauge = true
 				case "marker":
 					isMarker = true
+				case "lttb", "minmax":
+					downsample = n[0]
 				default:
+					if sourceNames[n[0]] || strings.Contains(n[0], "=") {
+						// field of a named source, like a:mem.heap, or
+						// expression using some, like d=b:mem.heap-a:mem.heap
+						break options
+					}
 					log.Fatalf("Invalid field option: %s", n[0])
 				}
 				n = n[1:]
 			}
-			name = n[0]
+			name = strings.Join(n, ":")
 			if strings.HasPrefix(name, "counter:") {
 				isCounter = true
 				name = name[8:]
@@ -111,53 +518,113 @@ func parseSpec(args []string) []data.GraphSpec {
 				name = name[7:]
 			}
 
-			gs.Fields = append(gs.Fields, data.Field{
-				ID:      fmt.Sprintf("%d.%d.%s", i, j, name),
-				Name:    name,
-				Counter: isCounter,
-				Marker:  isMarker,
-			})
+			f, err := parseField(name)
+			if err != nil {
+				log.Fatalf("Invalid field %s: %v", name, err)
+			}
+			f.ID = fmt.Sprintf("%d.%d.%s", i, j, name)
+			f.Counter = isCounter
+			f.Rate = isRate
+			f.Gauge = isGauge
+			f.Marker = isMarker
+			f.Downsample = downsample
+			gs.Fields = append(gs.Fields, f)
 		}
 		specs = append(specs, gs)
 	}
 	return specs
 }
 
-// Ready is an atomic boolean based on int32
-type Ready struct {
-	int32
-}
-
-func NewAtomicReady(ready bool) *Ready {
-	b := &Ready{}
-	b.SetReady(ready)
-	return b
+// recordSource wraps s to record its results to the --record file, if any.
+func recordSource(s source.Getter) source.Getter {
+	if recordFile == "" {
+		return s
+	}
+	r, err := source.NewRecorder(s, recordFile)
+	if err != nil {
+		log.Fatalf("Cannot record: %v", err)
+	}
+	for name := range sourceNames {
+		r.Sources = append(r.Sources, name)
+	}
+	sort.Strings(r.Sources)
+	return r
 }
 
-func (b *Ready) Ready() bool {
-	i := atomic.LoadInt32(&b.int32)
-	return i != 0
+// parseField parses a field path, a label selector, an aggregation or an
+// expression. An expression may be given a display name using the name=expr
+// syntax. Without a display name, the input is only handled as an expression
+// if it is not a selector, so max(x) is an aggregation and a/b is a path.
+func parseField(name string) (data.Field, error) {
+	var alias string
+	if parts := splitSpec(name, '='); len(parts) == 2 {
+		alias, name = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	}
+	if alias == "" && strings.TrimSpace(name) != name {
+		// like the b of a + b, additions needing parentheses or a name
+		return data.Field{}, fmt.Errorf("unexpected spaces around %q", strings.TrimSpace(name))
+	}
+	if alias != "" {
+		expr, err := data.ParseExpr(name)
+		if err != nil {
+			return data.Field{}, err
+		}
+		return data.Field{Name: alias, Expr: expr}, nil
+	}
+	f, err := data.ParseSelector(name)
+	if err == nil && isPath(f.Name) {
+		return f, nil
+	}
+	if expr, eerr := data.ParseExpr(name); eerr == nil && !expr.IsField() {
+		return data.Field{Name: name, Expr: expr}, nil
+	}
+	return f, err
 }
 
-func (b *Ready) MarkReady() {
-	b.SetReady(true)
+// splitFields splits the fields of a graph around +. Once a field is named
+// with name=, the rest of the argument is its expression, so additions like
+// total=a + b are not split.
+func splitFields(s string) []string {
+	parts := splitSpec(s, '+')
+	for i, p := range parts {
+		if len(splitSpec(p, '=')) > 1 {
+			return append(parts[:i], strings.Join(parts[i:], "+"))
+		}
+	}
+	return parts
 }
 
-func (b *Ready) SetReady(v bool) {
-	if v {
-		atomic.StoreInt32(&b.int32, 1)
-		return
-	}
-	atomic.StoreInt32(&b.int32, 0)
+// isPath tells if name is a field path, as opposed to an expression with
+// operators surrounded by spaces or parentheses.
+func isPath(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t(),")
 }
 
-func (b *Ready) MarkReadyIf(oldValue, newValue bool) bool {
-	var o, n int32
-	if oldValue {
-		o = 1
-	}
-	if newValue {
-		n = 1
+// splitSpec splits s around sep, ignoring separators found within braces,
+// parentheses or double quotes so label values like {instance="host:80"}
+// are kept intact.
+func splitSpec(s string, sep rune) []string {
+	var parts []string
+	var depth int
+	var quoted, escaped bool
+	start := 0
+	for i, c := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '{' || c == '(':
+			depth++
+		case c == '}' || c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
 	}
-	return atomic.CompareAndSwapInt32(&b.int32, o, n)
+	return append(parts, s[start:])
 }
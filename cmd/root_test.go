@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/jplot/data"
+)
+
+func TestSplitFields(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"a+b", []string{"a", "b"}},
+		{"counter:a+marker:b", []string{"counter:a", "marker:b"}},
+		{"(a + b)+c", []string{"(a + b)", "c"}},
+		{`x{code="a+b"}+y`, []string{`x{code="a+b"}`, "y"}},
+		{"total=a + b.c", []string{"total=a + b.c"}},
+		{"a+total=b + c", []string{"a", "total=b + c"}},
+		{`x{code="5"}+y`, []string{`x{code="5"}`, "y"}},
+		{"sum by (code)(x)+y", []string{"sum by (code)(x)", "y"}},
+	}
+	for _, tt := range tests {
+		if got := splitFields(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitFields(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseField(t *testing.T) {
+	tests := []struct {
+		in          string
+		name        string
+		expr        bool
+		aggregation string
+		wantErr     bool
+	}{
+		{in: "mem.heap", name: "mem.heap"},
+		{in: "handlers./api.count", name: "handlers./api.count"},
+		{in: "bytes-in", name: "bytes-in"},
+		{in: "max(g)", name: "g", aggregation: "max"},
+		{in: "sum by (code)(x)", name: "x", aggregation: "sum"},
+		{in: "a / b", name: "a / b", expr: true},
+		{in: "max(a, b)", name: "max(a, b)", expr: true},
+		{in: "total=a + b.c", name: "total", expr: true},
+		{in: "heap% = mem.heap / mem.sys * 100", name: "heap%", expr: true},
+		{in: "d=b:x - a:x", name: "d", expr: true},
+		{in: "one=x", name: "one", expr: true},
+		{in: "a ", wantErr: true},
+		{in: " b.c", wantErr: true},
+		{in: "total=a +", wantErr: true},
+	}
+	for _, tt := range tests {
+		f, err := parseField(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseField(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if f.Name != tt.name || (f.Expr != nil) != tt.expr || f.Aggregation != tt.aggregation {
+			t.Errorf("parseField(%q) = name %q, expression %v, aggregation %q, want %q, %v, %q",
+				tt.in, f.Name, f.Expr != nil, f.Aggregation, tt.name, tt.expr, tt.aggregation)
+		}
+	}
+}
+
+func TestExportData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jplot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(f string) { exportFile = f }(exportFile)
+	exportFile = filepath.Join(dir, "export.csv")
+
+	ds := &data.DataSet{Size: 10}
+	ds.Push("x", time.Unix(0, 0), 1, data.Gauge)
+	specs := []data.GraphSpec{{Fields: []data.Field{{ID: "x", Name: "x"}}}}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := exportData(specs, ds); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	b, err := ioutil.ReadFile(exportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "time,x\n1970-01-01T00:00:00Z,1\n"; string(b) != want {
+		t.Errorf("export = %q, want %q", b, want)
+	}
+	// no temporary file left behind
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("%d files in the export directory, want 1", len(files))
+	}
+}
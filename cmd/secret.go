@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// resolveSecret returns the value of the environment variable VAR for
+// env:VAR, the content of the file at path for file:path, minus the trailing
+// newline, and v itself otherwise. This keeps secrets out of the command
+// line and the shell history.
+func resolveSecret(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, "env:"):
+		name := v[len("env:"):]
+		s, found := os.LookupEnv(name)
+		if !found {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return s, nil
+	case strings.HasPrefix(v, "file:"):
+		b, err := ioutil.ReadFile(v[len("file:"):])
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+	return v, nil
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jplot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"password":      "s3cret\n",
+		"crlf":          "s3cret\r\n",
+		"multiline":     "line1\nline2\n",
+		"no-newline":    "s3cret",
+		"leading-space": " s3cret \n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Setenv("JPLOT_TEST_SECRET", "from-env")
+	defer os.Unsetenv("JPLOT_TEST_SECRET")
+	os.Setenv("JPLOT_TEST_EMPTY", "")
+	defer os.Unsetenv("JPLOT_TEST_EMPTY")
+	os.Unsetenv("JPLOT_TEST_UNSET")
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "plain", want: "plain"},
+		{in: "", want: ""},
+		{in: "user:env:x", want: "user:env:x"},
+		{in: "env:JPLOT_TEST_SECRET", want: "from-env"},
+		{in: "env:JPLOT_TEST_EMPTY", want: ""},
+		{in: "env:JPLOT_TEST_UNSET", wantErr: true},
+		{in: "file:" + filepath.Join(dir, "password"), want: "s3cret"},
+		{in: "file:" + filepath.Join(dir, "crlf"), want: "s3cret"},
+		{in: "file:" + filepath.Join(dir, "multiline"), want: "line1\nline2"},
+		{in: "file:" + filepath.Join(dir, "no-newline"), want: "s3cret"},
+		{in: "file:" + filepath.Join(dir, "leading-space"), want: " s3cret "},
+		{in: "file:" + filepath.Join(dir, "missing"), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := resolveSecret(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveSecret(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolveSecret(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package cmd
+
+import "os"
+
+// exportSignals are the signals triggering an export of the values.
+var exportSignals []os.Signal
@@ -0,0 +1,12 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// exportSignals are the signals triggering an export of the values.
+var exportSignals = []os.Signal{syscall.SIGUSR1}
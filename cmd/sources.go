@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rs/jplot/data"
+	"github.com/rs/jplot/source"
+)
+
+// sourceNames holds the names given to sources with the name=uri syntax. The
+// fields of a named source are qualified with its name, like a:mem.heap.
+var sourceNames = map[string]bool{}
+
+// fieldOptions are the field prefixes which cannot be used as source names.
+var fieldOptions = map[string]bool{
+	"counter": true,
+	"rate":    true,
+	"gauge":   true,
+	"marker":  true,
+	"lttb":    true,
+	"minmax":  true,
+}
+
+var sourceNameRe = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_-]*)=(.*)$`)
+
+// namedURI is the URI of a source with its optional name.
+type namedURI struct {
+	name string
+	uri  string
+}
+
+// parseSourceURIs parses source arguments given as uri or name=uri and
+// registers the names in sourceNames. It must be called before parseSpec.
+func parseSourceURIs(args []string) ([]namedURI, error) {
+	uris := make([]namedURI, 0, len(args))
+	for _, arg := range args {
+		var u namedURI
+		if m := sourceNameRe.FindStringSubmatch(arg); m != nil {
+			u.name, u.uri = m[1], m[2]
+			if fieldOptions[u.name] {
+				return nil, fmt.Errorf("invalid source name %s: reserved for the field option", u.name)
+			}
+			if sourceNames[u.name] {
+				return nil, fmt.Errorf("duplicate source name %s", u.name)
+			}
+			sourceNames[u.name] = true
+		} else {
+			u.uri = arg
+		}
+		uris = append(uris, u)
+	}
+	return uris, nil
+}
+
+// openSources opens the sources of uris with open, with the interval, timeout
+// and HTTP options of the command line, each one being given the fields of
+// specs it must provide. The values of named sources are qualified with their
+// names and, if there are several sources, they are fetched concurrently and
+// merged.
+func openSources(uris []namedURI, specs []data.GraphSpec, open source.Opener) (source.Getter, error) {
+	getters := make([]source.Getter, 0, len(uris))
+	for _, u := range uris {
+		opts := httpOptions
+		opts.Interval = interval
+		opts.Timeout = timeout
+		opts.Specs = sourceSpecs(specs, u.name)
+		g, err := open(u.uri, opts)
+		if err != nil {
+			for _, g := range getters {
+				g.Close()
+			}
+			return nil, err
+		}
+		if u.name != "" {
+			g = source.NewNamed(u.name, g)
+		}
+		getters = append(getters, g)
+	}
+	if len(getters) == 1 {
+		return getters[0], nil
+	}
+	return source.Merge(getters...), nil
+}
+
+// sourceSpecs returns the fields of specs provided by the source named name,
+// or by unnamed sources if name is empty, with their names unqualified. The
+// fields used by expressions are returned as plain fields.
+func sourceSpecs(specs []data.GraphSpec, name string) []data.GraphSpec {
+	var fields []data.Field
+	for _, gs := range specs {
+		for _, f := range gs.Fields {
+			if f.Expr != nil {
+				for _, n := range f.Expr.Fields() {
+					if src, field := splitQualified(n); src == name {
+						fields = append(fields, data.Field{Name: field})
+					}
+				}
+				continue
+			}
+			if src, field := splitQualified(f.Name); src == name {
+				f.SetName(field)
+				fields = append(fields, f)
+			}
+		}
+	}
+	return []data.GraphSpec{{Fields: fields}}
+}
+
+// splitQualified splits a field name qualified by a source name into the
+// source name and the field name. The source name is empty if the field is
+// not qualified.
+func splitQualified(name string) (string, string) {
+	if i := strings.IndexByte(name, ':'); i > 0 && sourceNames[name[:i]] {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/rs/jplot/source"
+	"github.com/spf13/cobra"
+)
+
+// stdinCmd represents the stdin command
+var stdinCmd = &cobra.Command{
+	Use:   "stdin",
+	Short: "Graph using JSON lines read from stdin",
+	Long: `Graph using JSON lines read from stdin
+
+Each line must be a JSON object. Nested objects are flattened using dots, the
+same way as with expvar. A last frame is rendered once the input is closed.
+
+Example:
+
+    while true; do curl -s http://:8080/debug/vars; echo; sleep 1; done | \
+        jplot stdin mem.heap+mem.sys+mem.stack counter:cpu.sTime+cpu.uTime threads
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		exit(runStdin(args))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stdinCmd)
+}
+
+func runStdin(args []string) error {
+	specs := parseSpec(args)
+	return run(source.NewStdin(), specs)
+}
@@ -1,7 +1,9 @@
 package data
 
 import (
+	"math"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -19,6 +21,17 @@ func (p *Point) Equal(o Point) bool {
 	return p.Timestamp.Equal(o.Timestamp) && p.Value == o.Value
 }
 
+// Gap returns a point marking that no value was available at ts, like when a
+// field is missing from the source. Series are not drawn across gaps.
+func Gap(ts time.Time) Point {
+	return Point{Timestamp: ts, Value: math.NaN()}
+}
+
+// IsGap tells if p is a gap marker.
+func (p Point) IsGap() bool {
+	return math.IsNaN(p.Value)
+}
+
 type Points []Point
 
 func (p Points) Len() int {
@@ -33,6 +46,25 @@ func (p Points) Swap(i, j int) {
 	p[i], p[j] = p[j], p[i]
 }
 
+// Segments splits the points around gaps. Gap markers are not part of the
+// returned segments, which are never empty.
+func (p Points) Segments() []Points {
+	var segments []Points
+	start := 0
+	for i, v := range p {
+		if v.IsGap() {
+			if i > start {
+				segments = append(segments, p[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(p) {
+		segments = append(segments, p[start:])
+	}
+	return segments
+}
+
 func (p Points) XYValues() ([]time.Time, []float64) {
 	xVals := make([]time.Time, 0, p.Len())
 	yVals := make([]float64, 0, p.Len())
@@ -43,94 +75,228 @@ func (p Points) XYValues() ([]time.Time, []float64) {
 	return xVals, yVals
 }
 
+// SortedPointSet stores up to Size points ordered by timestamp in a ring
+// buffer. Appending a point newer than the others is O(1); late points are
+// inserted at their place. Once full, the oldest point is dropped.
+//
+// If MaxAge is set, points older than MaxAge compared to the newest point are
+// dropped instead, and Size is only the initial capacity of the buffer which
+// grows as needed.
 type SortedPointSet struct {
-	Size    int
-	timeMap map[time.Time]Point
-	last    Point
+	Size   int
+	MaxAge time.Duration
+	buf    []Point
+	start  int // index of the oldest point in buf
+	n      int // number of points in buf
 }
 
+// at returns a pointer to the i-th oldest point.
+func (s *SortedPointSet) at(i int) *Point {
+	return &s.buf[(s.start+i)%len(s.buf)]
+}
+
+// Add inserts p in the set. If a point already exists with the same
+// timestamp, its value is updated.
 func (s *SortedPointSet) Add(p Point) {
-	point, found := s.timeMap[p.Timestamp]
-	if !found {
-		if len(s.timeMap) < s.Size {
-			s.timeMap[p.Timestamp] = p
+	if s.buf == nil {
+		size := s.Size
+		if size < 1 {
+			size = 1
+		}
+		s.buf = make([]Point, size)
+	}
+	if s.n == 0 || s.at(s.n-1).Timestamp.Before(p.Timestamp) {
+		// common case: newest point
+		s.expire(p.Timestamp)
+		if s.n == len(s.buf) && s.MaxAge > 0 {
+			s.grow()
+		}
+		if s.n < len(s.buf) {
+			*s.at(s.n) = p
+			s.n++
 		} else {
-			if s.last.Timestamp.Unix() <= 0 { // initialization case
-				s.last = p
-				s.timeMap[p.Timestamp] = p
-			} else if s.last.Timestamp.Before(p.Timestamp) {
-				// last one can be dropped
-				delete(s.timeMap, s.last.Timestamp)
-				s.timeMap[p.Timestamp] = p
-				// find the oldest
-				oldest := p.Timestamp
-				for k, _ := range s.timeMap {
-					if k.Before(oldest) {
-						oldest = k
-					}
-				}
-				s.last = s.timeMap[oldest]
-			}
+			s.buf[s.start] = p
+			s.start = (s.start + 1) % len(s.buf)
+		}
+		return
+	}
+	if s.MaxAge > 0 && p.Timestamp.Before(s.at(s.n-1).Timestamp.Add(-s.MaxAge)) {
+		// out of the time window
+		return
+	}
+	// find the first point not older than p
+	i := sort.Search(s.n, func(i int) bool {
+		return !s.at(i).Timestamp.Before(p.Timestamp)
+	})
+	if i < s.n && s.at(i).Timestamp.Equal(p.Timestamp) {
+		s.at(i).Value = p.Value
+		return
+	}
+	if s.n == len(s.buf) && s.MaxAge > 0 {
+		s.grow()
+	}
+	if s.n == len(s.buf) {
+		if i == 0 {
+			// older than everything we keep
+			return
 		}
-	} else {
-		// update value
-		point.Value = p.Value
+		// drop the oldest point to make room
+		s.start = (s.start + 1) % len(s.buf)
+		s.n--
+		i--
+	}
+	for j := s.n; j > i; j-- {
+		*s.at(j) = *s.at(j - 1)
+	}
+	*s.at(i) = p
+	s.n++
+}
+
+// expire drops the points older than MaxAge compared to newest.
+func (s *SortedPointSet) expire(newest time.Time) {
+	if s.MaxAge <= 0 {
+		return
+	}
+	limit := newest.Add(-s.MaxAge)
+	for s.n > 0 && s.at(0).Timestamp.Before(limit) {
+		s.start = (s.start + 1) % len(s.buf)
+		s.n--
 	}
 }
 
+// grow doubles the capacity of the buffer.
+func (s *SortedPointSet) grow() {
+	buf := make([]Point, 2*len(s.buf))
+	for i := 0; i < s.n; i++ {
+		buf[i] = *s.at(i)
+	}
+	s.buf = buf
+	s.start = 0
+}
+
+// Points returns a copy of the points, oldest first.
 func (s *SortedPointSet) Points() Points {
-	points := make(Points, 0)
-	for _, v := range s.timeMap {
-		points = append(points, v)
+	points := make(Points, s.n)
+	for i := range points {
+		points[i] = *s.at(i)
 	}
-	sort.Sort(points)
 	return points
 }
 
 func (s *SortedPointSet) Len() int {
-	return len(s.timeMap)
+	return s.n
 }
 
 type DataSet struct {
 	// Size is the number of data point to store per metric.
-	Size              int
+	Size int
+	// Window, if set, is the time span of the points to store per metric
+	// instead of Size.
+	Window time.Duration
+	// ExpectedFrequency is the expected interval between two points. With
+	// Window, it is used to preallocate the storage of each metric.
 	ExpectedFrequency time.Duration
+	// Tiers, if set, replaces Size and Window for the retention of points:
+	// raw points are kept for the retention of the first tier, and
+	// downsampled for the following ones. Window is then only the time span
+	// requested by GetRange.
+	Tiers []Tier
+
+	points  map[string]*SortedPointSet
+	buckets map[string][]*bucketSet
+	last    map[string]Point
+	order   []string
+	mu      sync.Mutex
+}
 
-	points map[string]SortedPointSet
-	last   map[string]Point
-	mu     sync.Mutex
+// Mode defines how the values pushed to a DataSet are stored.
+type Mode int
+
+const (
+	// Gauge stores values as is.
+	Gauge Mode = iota
+	// Counter stores the increase since the previous value. A decrease is
+	// handled as a counter reset: the counter is assumed to have restarted
+	// from zero, so the new value is the increase.
+	Counter
+	// Rate stores the per-second increase since the previous value, with
+	// counter resets handled as for Counter.
+	Rate
+)
+
+func (ds *DataSet) Push(name string, ts time.Time, value float64, mode Mode) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if p, ok := ds.transform(name, Point{Timestamp: ts, Value: value}, mode); ok {
+		ds.pushPoint(name, p)
+	}
+}
+
+// PushGap marks that no value was available for name at ts. The state of
+// counters is kept, so the value following the gap is computed from the one
+// before it.
+func (ds *DataSet) PushGap(name string, ts time.Time) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.pushPoint(name, Gap(ts))
 }
 
-func (ds *DataSet) Push(name string, ts time.Time, value float64, counter bool) {
+func (ds *DataSet) PushPoints(name string, data Points, mode Mode) {
+	sort.Sort(&data)
 	ds.mu.Lock()
 	defer ds.mu.Unlock()
-	if counter {
-		var diff float64
-		if last := ds.last[name]; last.Value > 0 {
-			diff = value - last.Value
+	for _, p := range data {
+		if p, ok := ds.transform(name, p, mode); ok {
+			ds.pushPoint(name, p)
 		}
-		ds.last[name] = Point{Timestamp: ts, Value: value}
-		value = diff
 	}
-	newPoint := Point{Timestamp: ts, Value: value}
-	ds.pushPoint(name, newPoint)
 }
 
-func (ds *DataSet) PushPoints(name string, data Points, counter bool) {
+// Transform applies mode to data like PushPoints, keeping the counter state
+// under name, but returns the resulting points instead of storing them. It is
+// used to compute the increase of each series of an aggregation before
+// combining them.
+func (ds *DataSet) Transform(name string, data Points, mode Mode) Points {
 	sort.Sort(&data)
 	ds.mu.Lock()
 	defer ds.mu.Unlock()
+	res := make(Points, 0, len(data))
 	for _, p := range data {
-		if counter {
-			var diff float64
-			if last := ds.last[name]; last.Value > 0 {
-				diff = p.Value - last.Value
-			}
-			ds.last[name] = Point{Timestamp: p.Timestamp, Value: p.Value}
-			p.Value = diff
+		if p, ok := ds.transform(name, p, mode); ok {
+			res = append(res, p)
 		}
-		ds.pushPoint(name, p)
 	}
+	return res
+}
+
+// transform applies mode to p. It returns false if there is no point to
+// store, like for the first value of a counter or a value not newer than the
+// previous one.
+func (ds *DataSet) transform(name string, p Point, mode Mode) (Point, bool) {
+	if mode == Gauge {
+		return p, true
+	}
+	if ds.last == nil {
+		ds.last = make(map[string]Point)
+	}
+	last, found := ds.last[name]
+	if found && !last.Timestamp.Before(p.Timestamp) {
+		// already accounted for
+		return p, false
+	}
+	ds.last[name] = p
+	if !found {
+		return p, false
+	}
+	diff := p.Value - last.Value
+	if diff < 0 {
+		// counter reset
+		diff = p.Value
+	}
+	if mode == Rate {
+		diff /= p.Timestamp.Sub(last.Timestamp).Seconds()
+	}
+	return Point{Timestamp: p.Timestamp, Value: diff}, true
 }
 
 func (ds *DataSet) Get(name string) Points {
@@ -140,24 +306,105 @@ func (ds *DataSet) Get(name string) Points {
 	return sps.Points()
 }
 
+// GetRange returns the points of the last span of time, or all points if
+// span is 0. With Tiers, the finest tier holding span is used, unless it has
+// more than width points for span, in which case a coarser tier is used.
+// Downsampled tiers return the average of each bucket; min and max are the
+// minimum and maximum of each bucket, or nil for raw points.
+func (ds *DataSet) GetRange(name string, span time.Duration, width int) (avg, min, max Points) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	raw := ds.getLocked(name)
+	if len(ds.Tiers) < 2 {
+		return since(raw.Points(), span), nil, nil
+	}
+	buckets := ds.buckets[name]
+	for i, t := range ds.Tiers {
+		coarsest := i == len(ds.Tiers)-1
+		if span > 0 && t.Retention < span && !coarsest {
+			continue
+		}
+		if i == 0 {
+			avg = since(raw.Points(), span)
+			if width <= 0 || len(avg) <= width || coarsest {
+				return avg, nil, nil
+			}
+			continue
+		}
+		if width > 0 && span > 0 && int(span/t.Resolution) > width && !coarsest {
+			continue
+		}
+		b := buckets[i-1]
+		return since(b.avg.Points(), span), since(b.min.Points(), span), since(b.max.Points(), span)
+	}
+	return nil, nil, nil
+}
+
+// since returns the points of the last span of time.
+func since(points Points, span time.Duration) Points {
+	if span <= 0 || len(points) == 0 {
+		return points
+	}
+	start := points[len(points)-1].Timestamp.Add(-span)
+	i := sort.Search(len(points), func(i int) bool {
+		return !points[i].Timestamp.Before(start)
+	})
+	return points[i:]
+}
+
+// Series returns the DataSet names of the series stored for f and their
+// display names. Static fields have a single series stored under their ID.
+// Series of dynamic fields are returned in the order they first appeared.
+func (ds *DataSet) Series(f Field) (ids, names []string) {
+	if !f.Dynamic() {
+		return []string{f.ID}, []string{f.Name}
+	}
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	prefix := f.SeriesID("")
+	for _, name := range ds.order {
+		if strings.HasPrefix(name, prefix) {
+			ids = append(ids, name)
+			names = append(names, name[len(prefix):])
+		}
+	}
+	return ids, names
+}
+
 func (ds *DataSet) pushPoint(name string, p Point) {
-	d := ds.getLocked(name)
-	d.Add(p)
-	ds.points[name] = d
+	ds.getLocked(name).Add(p)
+	for _, b := range ds.buckets[name] {
+		b.add(p)
+	}
 }
 
-func (ds *DataSet) getLocked(name string) SortedPointSet {
+func (ds *DataSet) getLocked(name string) *SortedPointSet {
 	if ds.points == nil {
-		ds.points = make(map[string]SortedPointSet, 0)
-		ds.last = make(map[string]Point)
+		ds.points = make(map[string]*SortedPointSet, 0)
 	}
 	d, found := ds.points[name]
 	if !found {
-		d = SortedPointSet{
-			Size:    ds.Size,
-			timeMap: make(map[time.Time]Point, 0),
+		d = &SortedPointSet{
+			Size: ds.Size,
+		}
+		retention := ds.Window
+		if len(ds.Tiers) > 0 {
+			retention = ds.Tiers[0].Retention
+			if ds.buckets == nil {
+				ds.buckets = make(map[string][]*bucketSet)
+			}
+			for _, t := range ds.Tiers[1:] {
+				ds.buckets[name] = append(ds.buckets[name], newBucketSet(t))
+			}
+		}
+		if retention > 0 {
+			d.MaxAge = retention
+			if ds.ExpectedFrequency > 0 {
+				d.Size = int(retention/ds.ExpectedFrequency) + 1
+			}
 		}
 		ds.points[name] = d
+		ds.order = append(ds.order, name)
 	}
 	return d
 }
@@ -0,0 +1,235 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// at returns the time sec seconds after epoch.
+func at(sec float64) time.Time {
+	return epoch.Add(time.Duration(sec * float64(time.Second)))
+}
+
+// pointsEqual tells if a and b have the same timestamps and values, gaps
+// being equal to each other.
+func pointsEqual(a, b Points) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Timestamp.Equal(b[i].Timestamp) || a[i].IsGap() != b[i].IsGap() {
+			return false
+		}
+		if !a[i].IsGap() && math.Abs(a[i].Value-b[i].Value) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDataSetPush(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name string
+		mode Mode
+		in   []Point
+		want Points
+	}{
+		{
+			name: "gauge",
+			mode: Gauge,
+			in:   []Point{{at(0), 5}, {at(1), 3}, {at(2), 8}},
+			want: Points{{at(0), 5}, {at(1), 3}, {at(2), 8}},
+		},
+		{
+			name: "counter",
+			mode: Counter,
+			in:   []Point{{at(0), 10}, {at(1), 15}, {at(2), 15}, {at(3), 22}},
+			want: Points{{at(1), 5}, {at(2), 0}, {at(3), 7}},
+		},
+		{
+			name: "counter reset",
+			mode: Counter,
+			in:   []Point{{at(0), 100}, {at(1), 110}, {at(2), 4}, {at(3), 9}},
+			want: Points{{at(1), 10}, {at(2), 4}, {at(3), 5}},
+		},
+		{
+			name: "rate uneven intervals",
+			mode: Rate,
+			in:   []Point{{at(0), 0}, {at(2), 10}, {at(2.5), 20}, {at(6.5), 40}},
+			want: Points{{at(2), 5}, {at(2.5), 20}, {at(6.5), 5}},
+		},
+		{
+			name: "rate reset",
+			mode: Rate,
+			in:   []Point{{at(0), 50}, {at(2), 60}, {at(4), 8}},
+			want: Points{{at(2), 5}, {at(4), 4}},
+		},
+		{
+			name: "counter duplicate and older timestamps",
+			mode: Counter,
+			in:   []Point{{at(0), 1}, {at(2), 5}, {at(2), 7}, {at(1), 3}, {at(3), 8}},
+			want: Points{{at(2), 4}, {at(3), 3}},
+		},
+		{
+			name: "gauge duplicate timestamp",
+			mode: Gauge,
+			in:   []Point{{at(0), 1}, {at(1), 2}, {at(1), 3}},
+			want: Points{{at(0), 1}, {at(1), 3}},
+		},
+		{
+			name: "counter gap",
+			mode: Counter,
+			in:   []Point{{at(0), 10}, {at(1), 12}, {at(2), nan}, {at(3), nan}, {at(4), 20}},
+			want: Points{{at(1), 2}, Gap(at(2)), Gap(at(3)), {at(4), 8}},
+		},
+		{
+			name: "rate gap",
+			mode: Rate,
+			in:   []Point{{at(0), 10}, {at(1), 12}, {at(2), nan}, {at(5), 20}},
+			want: Points{{at(1), 2}, Gap(at(2)), {at(5), 2}},
+		},
+		{
+			name: "gauge gap",
+			mode: Gauge,
+			in:   []Point{{at(0), 1}, {at(1), nan}, {at(2), 3}},
+			want: Points{{at(0), 1}, Gap(at(1)), {at(2), 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := &DataSet{Size: 100}
+			for _, p := range tt.in {
+				if p.IsGap() {
+					ds.PushGap("x", p.Timestamp)
+					continue
+				}
+				ds.Push("x", p.Timestamp, p.Value, tt.mode)
+			}
+			if got := ds.Get("x"); !pointsEqual(got, tt.want) {
+				t.Errorf("Get() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDataSetTransform(t *testing.T) {
+	ds := &DataSet{Size: 10}
+	got := ds.Transform("x", Points{{at(1), 15}, {at(0), 10}}, Counter)
+	if want := (Points{{at(1), 5}}); !pointsEqual(got, want) {
+		t.Errorf("Transform() = %v, want %v", got, want)
+	}
+	// the state is kept for the next call
+	got = ds.Transform("x", Points{{at(1), 15}, {at(2), 18}}, Counter)
+	if want := (Points{{at(2), 3}}); !pointsEqual(got, want) {
+		t.Errorf("Transform() = %v, want %v", got, want)
+	}
+	// nothing is stored
+	if got := ds.Get("x"); len(got) != 0 {
+		t.Errorf("Get() = %v, want no points", got)
+	}
+}
+
+// seconds returns the points at the given seconds after epoch, with the
+// seconds as value.
+func seconds(secs ...float64) Points {
+	points := make(Points, 0, len(secs))
+	for _, s := range secs {
+		points = append(points, Point{at(s), s})
+	}
+	return points
+}
+
+func TestSortedPointSet(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		maxAge time.Duration
+		in     []float64
+		want   Points
+	}{
+		{"in order", 5, 0, []float64{1, 2, 3}, seconds(1, 2, 3)},
+		{"full", 3, 0, []float64{1, 2, 3, 4, 5}, seconds(3, 4, 5)},
+		{"late", 5, 0, []float64{1, 3, 2, 5, 4}, seconds(1, 2, 3, 4, 5)},
+		{"late in full buffer", 3, 0, []float64{1, 3, 4, 2}, seconds(2, 3, 4)},
+		{"late older than full buffer", 3, 0, []float64{2, 3, 4, 1}, seconds(2, 3, 4)},
+		{"late after wraparound", 4, 0, []float64{1, 2, 3, 4, 6, 7, 5}, seconds(4, 5, 6, 7)},
+		{"wraparound", 3, 0, []float64{1, 2, 3, 4, 5, 6, 7, 8}, seconds(6, 7, 8)},
+		{"max age grows", 2, 10 * time.Second, []float64{1, 2, 3, 4, 5, 6}, seconds(1, 2, 3, 4, 5, 6)},
+		{"max age grows with late points", 2, 10 * time.Second, []float64{1, 4, 2, 5, 3}, seconds(1, 2, 3, 4, 5)},
+		{"max age expiry", 2, 10 * time.Second, []float64{1, 2, 3, 12, 14}, seconds(12, 14)},
+		{"max age late expired", 4, 10 * time.Second, []float64{10, 20, 5, 15}, seconds(10, 15, 20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &SortedPointSet{Size: tt.size, MaxAge: tt.maxAge}
+			for _, sec := range tt.in {
+				s.Add(Point{at(sec), sec})
+			}
+			if got := s.Points(); !pointsEqual(got, tt.want) {
+				t.Errorf("Points() = %v, want %v", got, tt.want)
+			}
+			if s.Len() != len(tt.want) {
+				t.Errorf("Len() = %d, want %d", s.Len(), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestSortedPointSetUpdate(t *testing.T) {
+	s := &SortedPointSet{Size: 3}
+	for _, sec := range []float64{1, 2, 3, 4} {
+		s.Add(Point{at(sec), sec})
+	}
+	s.Add(Point{at(3), 30})
+	s.Add(Point{at(4), 40})
+	want := Points{{at(2), 2}, {at(3), 30}, {at(4), 40}}
+	if got := s.Points(); !pointsEqual(got, want) {
+		t.Errorf("Points() = %v, want %v", got, want)
+	}
+}
+
+// benchSeries is the number of series of the benchmarks, like a Prometheus
+// selector matching thousands of series.
+const benchSeries = 5000
+
+func benchNames() []string {
+	names := make([]string, benchSeries)
+	for i := range names {
+		names[i] = fmt.Sprintf("series%d", i)
+	}
+	return names
+}
+
+func BenchmarkDataSetPush(b *testing.B) {
+	names := benchNames()
+	ds := &DataSet{Window: 10 * time.Minute, ExpectedFrequency: time.Second}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ts := at(float64(i))
+		for _, name := range names {
+			ds.Push(name, ts, float64(i), Gauge)
+		}
+	}
+}
+
+func BenchmarkGetRange(b *testing.B) {
+	names := benchNames()
+	ds := &DataSet{Window: 10 * time.Minute, ExpectedFrequency: time.Second}
+	for i := 0; i < 600; i++ {
+		ts := at(float64(i))
+		for _, name := range names {
+			ds.Push(name, ts, float64(i), Gauge)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, name := range names {
+			ds.GetRange(name, ds.Window, 800)
+		}
+	}
+}
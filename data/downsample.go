@@ -0,0 +1,117 @@
+package data
+
+import "math"
+
+// LTTB downsamples the points to at most threshold points using the Largest
+// Triangle Three Buckets algorithm, which keeps the visual shape of the
+// series. Points are returned as is if they already fit.
+func (p Points) LTTB(threshold int) Points {
+	n := len(p)
+	if threshold >= n || threshold < 3 {
+		return p
+	}
+	x := func(i int) float64 {
+		return p[i].Timestamp.Sub(p[0].Timestamp).Seconds()
+	}
+	sampled := make(Points, 0, threshold)
+	sampled = append(sampled, p[0])
+	every := float64(n-2) / float64(threshold-2)
+	a := 0
+	for i := 0; i < threshold-2; i++ {
+		// average of the next bucket
+		avgStart := int(math.Floor(float64(i+1)*every)) + 1
+		avgEnd := int(math.Floor(float64(i+2)*every)) + 1
+		if avgEnd > n {
+			avgEnd = n
+		}
+		var avgX, avgY float64
+		for j := avgStart; j < avgEnd; j++ {
+			avgX += x(j)
+			avgY += p[j].Value
+		}
+		avgX /= float64(avgEnd - avgStart)
+		avgY /= float64(avgEnd - avgStart)
+
+		// point of the current bucket forming the largest triangle with the
+		// previously selected point and the average of the next bucket
+		rangeStart := int(math.Floor(float64(i)*every)) + 1
+		rangeEnd := int(math.Floor(float64(i+1)*every)) + 1
+		ax, ay := x(a), p[a].Value
+		maxArea := -1.0
+		next := rangeStart
+		for j := rangeStart; j < rangeEnd; j++ {
+			area := math.Abs((ax-avgX)*(p[j].Value-ay) - (ax-x(j))*(avgY-ay))
+			if area > maxArea {
+				maxArea = area
+				next = j
+			}
+		}
+		sampled = append(sampled, p[next])
+		a = next
+	}
+	return append(sampled, p[n-1])
+}
+
+// MinMax downsamples the points to at most threshold points by keeping the
+// minimum and maximum of threshold/2 buckets, drawing the envelope of the
+// series. Points are returned as is if they already fit.
+func (p Points) MinMax(threshold int) Points {
+	n := len(p)
+	buckets := threshold / 2
+	if threshold >= n || buckets < 1 {
+		return p
+	}
+	sampled := make(Points, 0, 2*buckets)
+	for b := 0; b < buckets; b++ {
+		start, end := b*n/buckets, (b+1)*n/buckets
+		lo, hi := start, start
+		for j := start + 1; j < end; j++ {
+			if p[j].Value < p[lo].Value {
+				lo = j
+			}
+			if p[j].Value > p[hi].Value {
+				hi = j
+			}
+		}
+		switch {
+		case lo == hi:
+			sampled = append(sampled, p[lo])
+		case lo < hi:
+			sampled = append(sampled, p[lo], p[hi])
+		default:
+			sampled = append(sampled, p[hi], p[lo])
+		}
+	}
+	return sampled
+}
+
+// Reduce downsamples the points of the field to width points using the
+// field's Downsample method, if any. Segments between gaps are downsampled
+// separately, in proportion to their number of points.
+func (f Field) Reduce(p Points, width int) Points {
+	var reduce func(Points, int) Points
+	switch f.Downsample {
+	case "lttb":
+		reduce = Points.LTTB
+	case "minmax":
+		reduce = Points.MinMax
+	default:
+		return p
+	}
+	if width >= len(p) {
+		return p
+	}
+	segments := p.Segments()
+	if len(segments) == 1 && len(segments[0]) == len(p) {
+		return reduce(p, width)
+	}
+	var reduced Points
+	for i, s := range segments {
+		if i > 0 {
+			// keep a gap between segments
+			reduced = append(reduced, Gap(s[0].Timestamp.Add(-1)))
+		}
+		reduced = append(reduced, reduce(s, width*len(s)/len(p))...)
+	}
+	return reduced
+}
@@ -0,0 +1,180 @@
+package data
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// wave returns n points a second apart with a noisy sine shape.
+func wave(n int) Points {
+	points := make(Points, n)
+	for i := range points {
+		points[i] = Point{at(float64(i)), math.Sin(float64(i)/10)*10 + float64(i%7)}
+	}
+	return points
+}
+
+// isSubset tells if every point of sub is a point of p, in the same order.
+func isSubset(sub, p Points) bool {
+	j := 0
+	for _, s := range sub {
+		for j < len(p) && !(p[j].Timestamp.Equal(s.Timestamp) && p[j].Value == s.Value) {
+			j++
+		}
+		if j == len(p) {
+			return false
+		}
+		j++
+	}
+	return true
+}
+
+func TestLTTB(t *testing.T) {
+	tests := []struct {
+		name      string
+		n         int
+		threshold int
+		wantLen   int
+	}{
+		{"downsampled", 1000, 100, 100},
+		{"uneven buckets", 997, 33, 33},
+		{"minimum threshold", 100, 3, 3},
+		{"fits", 50, 100, 50},
+		{"same size", 50, 50, 50},
+		{"threshold too small", 50, 2, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := wave(tt.n)
+			got := p.LTTB(tt.threshold)
+			if len(got) != tt.wantLen {
+				t.Fatalf("len = %d, want %d", len(got), tt.wantLen)
+			}
+			if !got[0].Equal(p[0]) || !got[len(got)-1].Equal(p[len(p)-1]) {
+				t.Errorf("first and last points = %v, %v, want %v, %v", got[0], got[len(got)-1], p[0], p[len(p)-1])
+			}
+			if !isSubset(got, p) {
+				t.Error("points are not a subset of the input in order")
+			}
+		})
+	}
+}
+
+func TestLTTBKeepsPeak(t *testing.T) {
+	p := make(Points, 100)
+	for i := range p {
+		p[i] = Point{at(float64(i)), 0}
+	}
+	p[42].Value = 100
+	got := p.LTTB(10)
+	var found bool
+	for _, v := range got {
+		found = found || v.Value == 100
+	}
+	if !found {
+		t.Errorf("LTTB() = %v, want the peak kept", got)
+	}
+}
+
+func TestMinMax(t *testing.T) {
+	tests := []struct {
+		name      string
+		n         int
+		threshold int
+	}{
+		{"downsampled", 1000, 100},
+		{"uneven buckets", 997, 33},
+		{"odd threshold", 100, 11},
+		{"two points", 100, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := wave(tt.n)
+			got := p.MinMax(tt.threshold)
+			if len(got) > tt.threshold {
+				t.Fatalf("len = %d, want at most %d", len(got), tt.threshold)
+			}
+			if !isSubset(got, p) {
+				t.Fatal("points are not a subset of the input in order")
+			}
+			// the min and max of every bucket are kept
+			buckets := tt.threshold / 2
+			for b := 0; b < buckets; b++ {
+				bucket := p[b*tt.n/buckets : (b+1)*tt.n/buckets]
+				lo, hi := math.Inf(1), math.Inf(-1)
+				for _, v := range bucket {
+					lo, hi = math.Min(lo, v.Value), math.Max(hi, v.Value)
+				}
+				var foundLo, foundHi bool
+				for _, v := range got {
+					if v.Timestamp.Before(bucket[0].Timestamp) || v.Timestamp.After(bucket[len(bucket)-1].Timestamp) {
+						continue
+					}
+					foundLo = foundLo || v.Value == lo
+					foundHi = foundHi || v.Value == hi
+				}
+				if !foundLo || !foundHi {
+					t.Errorf("bucket %d: min %v found %v, max %v found %v", b, lo, foundLo, hi, foundHi)
+				}
+			}
+		})
+	}
+}
+
+func TestMinMaxFits(t *testing.T) {
+	p := wave(10)
+	if got := p.MinMax(10); len(got) != 10 {
+		t.Errorf("len = %d, want the 10 points as is", len(got))
+	}
+	if got := p.MinMax(1); len(got) != 10 {
+		t.Errorf("len = %d, want the 10 points as is with a threshold too small", len(got))
+	}
+}
+
+func TestReduce(t *testing.T) {
+	// two segments of 600 and 300 points around a gap
+	var p Points
+	p = append(p, wave(600)...)
+	p = append(p, Gap(at(600)))
+	for _, v := range wave(300) {
+		v.Timestamp = v.Timestamp.Add(601 * time.Second)
+		p = append(p, v)
+	}
+	for _, method := range []string{"lttb", "minmax"} {
+		t.Run(method, func(t *testing.T) {
+			f := Field{Downsample: method}
+			got := f.Reduce(p, 90)
+			segments := got.Segments()
+			if len(segments) != 2 {
+				t.Fatalf("%d segments, want 2", len(segments))
+			}
+			if len(got) > 90+1 {
+				t.Errorf("len = %d, want at most 90 points and a gap", len(got))
+			}
+			gap := at(600)
+			for _, v := range segments[0] {
+				if !v.Timestamp.Before(gap) {
+					t.Errorf("point %v of the first segment after the gap", v)
+				}
+			}
+			for _, v := range segments[1] {
+				if !v.Timestamp.After(gap) {
+					t.Errorf("point %v of the second segment before the gap", v)
+				}
+			}
+			// segments get a share of the width in proportion to their points
+			if n0, n1 := len(segments[0]), len(segments[1]); n0 <= n1 {
+				t.Errorf("segments of %d and %d points, want the first one larger", n0, n1)
+			}
+		})
+	}
+
+	// without a method or with enough width, points are kept as is
+	if got := (Field{}).Reduce(p, 90); len(got) != len(p) {
+		t.Errorf("Reduce() without method = %d points, want %d", len(got), len(p))
+	}
+	if got := (Field{Downsample: "lttb"}).Reduce(p, 2000); len(got) != len(p) {
+		t.Errorf("Reduce() with enough width = %d points, want %d", len(got), len(p))
+	}
+}
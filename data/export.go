@@ -0,0 +1,93 @@
+package data
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// table returns the points of the series named ids aligned by timestamp: a
+// row per distinct timestamp, oldest first, with a column per series. Missing
+// values are NaN.
+func (ds *DataSet) table(ids []string) ([]time.Time, [][]float64) {
+	index := map[int64]int{}
+	var times []time.Time
+	columns := make([]Points, len(ids))
+	for i, id := range ids {
+		columns[i] = ds.Get(id)
+		for _, p := range columns[i] {
+			ts := p.Timestamp.UnixNano()
+			if _, found := index[ts]; !found {
+				index[ts] = len(times)
+				times = append(times, p.Timestamp)
+			}
+		}
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+	for i, t := range times {
+		index[t.UnixNano()] = i
+	}
+	rows := make([][]float64, len(times))
+	for i := range rows {
+		rows[i] = make([]float64, len(ids))
+		for j := range rows[i] {
+			rows[i][j] = math.NaN()
+		}
+	}
+	for j, points := range columns {
+		for _, p := range points {
+			rows[index[p.Timestamp.UnixNano()]][j] = p.Value
+		}
+	}
+	return times, rows
+}
+
+// WriteCSV writes the retained points of the series named ids as CSV, with a
+// time column followed by a column per series. Cells of series without a
+// point at a given time are left empty.
+func (ds *DataSet) WriteCSV(w io.Writer, ids []string) error {
+	times, rows := ds.table(ids)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"time"}, ids...)); err != nil {
+		return err
+	}
+	record := make([]string, len(ids)+1)
+	for i, t := range times {
+		record[0] = t.Format(time.RFC3339Nano)
+		for j, v := range rows[i] {
+			record[j+1] = ""
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				record[j+1] = strconv.FormatFloat(v, 'g', -1, 64)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the retained points of the series named ids as a JSON
+// array with an object per time holding the time and the value of each
+// series having a point at that time.
+func (ds *DataSet) WriteJSON(w io.Writer, ids []string) error {
+	times, rows := ds.table(ids)
+	objects := make([]map[string]interface{}, 0, len(times))
+	for i, t := range times {
+		o := map[string]interface{}{"time": t.Format(time.RFC3339Nano)}
+		for j, v := range rows[i] {
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				o[ids[j]] = v
+			}
+		}
+		objects = append(objects, o)
+	}
+	return json.NewEncoder(w).Encode(objects)
+}
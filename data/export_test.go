@@ -0,0 +1,84 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// exportDataSet returns a DataSet with two series pushed at different times,
+// a having a gap and b a late point.
+func exportDataSet() *DataSet {
+	ds := &DataSet{Size: 10}
+	ds.Push("a", at(0), 1, Gauge)
+	ds.PushGap("a", at(1))
+	ds.Push("a", at(2), 3.5, Gauge)
+	ds.Push("b", at(1), 10, Gauge)
+	ds.Push("b", at(3), math.Inf(1), Gauge)
+	ds.Push("b", at(0.5), 5, Gauge)
+	return ds
+}
+
+func TestWriteCSV(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := exportDataSet().WriteCSV(buf, []string{"a", "b", "missing"}); err != nil {
+		t.Fatal(err)
+	}
+	ts := func(sec float64) string {
+		return at(sec).Format(time.RFC3339Nano)
+	}
+	want := "time,a,b,missing\n" +
+		ts(0) + ",1,,\n" +
+		ts(0.5) + ",,5,\n" +
+		ts(1) + ",,10,\n" +
+		ts(2) + ",3.5,,\n" +
+		ts(3) + ",,,\n"
+	if got := buf.String(); got != want {
+		t.Errorf("WriteCSV() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := exportDataSet().WriteJSON(buf, []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", buf.Bytes(), err)
+	}
+	ts := func(sec float64) string {
+		return at(sec).Format(time.RFC3339Nano)
+	}
+	want := []map[string]interface{}{
+		{"time": ts(0), "a": 1.0},
+		{"time": ts(0.5), "b": 5.0},
+		{"time": ts(1), "b": 10.0}, // gap of a
+		{"time": ts(2), "a": 3.5},
+		{"time": ts(3)}, // infinite b
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WriteJSON() = %v, want %v", got, want)
+	}
+}
+
+func TestWriteEmpty(t *testing.T) {
+	ds := &DataSet{Size: 10}
+	buf := &bytes.Buffer{}
+	if err := ds.WriteCSV(buf, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "time,a\n"; got != want {
+		t.Errorf("WriteCSV() = %q, want %q", got, want)
+	}
+	buf.Reset()
+	if err := ds.WriteJSON(buf, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "[]\n"; got != want {
+		t.Errorf("WriteJSON() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,385 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Expr is an arithmetic expression computed from other fields, like
+// memstats.HeapInuse / memstats.HeapSys * 100.
+//
+// It supports the + - * / operators, parentheses, numeric constants and the
+// abs, min and max functions. Operands are field paths. A * directly
+// following a dot is a wildcard and a - or / between two letters or digits
+// is part of the path, so those operators must be surrounded by spaces when
+// ambiguous.
+type Expr struct {
+	root   exprNode
+	fields []string
+}
+
+type exprNode interface {
+	eval(vars map[string]float64) float64
+}
+
+type exprConst float64
+
+func (c exprConst) eval(map[string]float64) float64 { return float64(c) }
+
+type exprField string
+
+func (f exprField) eval(vars map[string]float64) float64 { return vars[string(f)] }
+
+type exprUnary struct {
+	x exprNode
+}
+
+func (u exprUnary) eval(vars map[string]float64) float64 { return -u.x.eval(vars) }
+
+type exprBinary struct {
+	op   byte
+	x, y exprNode
+}
+
+func (b exprBinary) eval(vars map[string]float64) float64 {
+	x, y := b.x.eval(vars), b.y.eval(vars)
+	switch b.op {
+	case '+':
+		return x + y
+	case '-':
+		return x - y
+	case '*':
+		return x * y
+	case '/':
+		return x / y
+	}
+	return math.NaN()
+}
+
+type exprCall struct {
+	fn   string
+	args []exprNode
+}
+
+func (c exprCall) eval(vars map[string]float64) float64 {
+	v := c.args[0].eval(vars)
+	switch c.fn {
+	case "abs":
+		return math.Abs(v)
+	case "min":
+		for _, a := range c.args[1:] {
+			v = math.Min(v, a.eval(vars))
+		}
+	case "max":
+		for _, a := range c.args[1:] {
+			v = math.Max(v, a.eval(vars))
+		}
+	}
+	return v
+}
+
+// exprFuncs lists the supported functions and their minimum number of
+// arguments.
+var exprFuncs = map[string]int{"abs": 1, "min": 1, "max": 1}
+
+// ParseExpr parses an arithmetic expression.
+func ParseExpr(s string) (*Expr, error) {
+	p := &exprParser{s: s}
+	root, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.s[p.pos:], p.pos)
+	}
+	e := &Expr{root: root}
+	seen := map[string]bool{}
+	e.walk(root, func(f string) {
+		if !seen[f] {
+			seen[f] = true
+			e.fields = append(e.fields, f)
+		}
+	})
+	for _, f := range e.fields {
+		if strings.Contains(f, "*") {
+			return nil, fmt.Errorf("wildcards are not supported in expressions: %s", f)
+		}
+	}
+	return e, nil
+}
+
+func (e *Expr) walk(n exprNode, fn func(string)) {
+	switch n := n.(type) {
+	case exprField:
+		fn(string(n))
+	case exprUnary:
+		e.walk(n.x, fn)
+	case exprBinary:
+		e.walk(n.x, fn)
+		e.walk(n.y, fn)
+	case exprCall:
+		for _, a := range n.args {
+			e.walk(a, fn)
+		}
+	}
+}
+
+// Fields returns the field paths referenced by the expression.
+func (e *Expr) Fields() []string {
+	return e.fields
+}
+
+// IsField tells if the expression is a lone field path.
+func (e *Expr) IsField() bool {
+	_, ok := e.root.(exprField)
+	return ok
+}
+
+// Eval computes the expression for each timestamp at which one of the
+// referenced fields has a point, using the latest value of every field at
+// that time, so fields fetched at slightly different times, like from
+// different sources, can be combined. Timestamps at which a field has no
+// value yet are skipped. Points with an infinite or NaN result, like after a
+// division by zero, are dropped.
+func (e *Expr) Eval(points map[string]Points) (Points, error) {
+	series := make([]Points, len(e.fields))
+	var times []time.Time
+	for i, f := range e.fields {
+		pts, found := points[f]
+		if !found {
+			return nil, fmt.Errorf("cannot get %s", f)
+		}
+		pts = append(Points(nil), pts...)
+		sort.Sort(pts)
+		series[i] = pts
+		for _, p := range pts {
+			times = append(times, p.Timestamp)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+	vars := make(map[string]float64, len(e.fields))
+	next := make([]int, len(series))
+	res := make(Points, 0, len(times))
+	for i, t := range times {
+		if i > 0 && t.Equal(times[i-1]) {
+			continue
+		}
+		complete := true
+		for j, pts := range series {
+			for next[j] < len(pts) && !pts[next[j]].Timestamp.After(t) {
+				next[j]++
+			}
+			if next[j] == 0 {
+				complete = false
+				continue
+			}
+			vars[e.fields[j]] = pts[next[j]-1].Value
+		}
+		if !complete {
+			continue
+		}
+		v := e.root.eval(vars)
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		res = append(res, Point{Timestamp: t, Value: v})
+	}
+	return res, nil
+}
+
+type exprParser struct {
+	s   string
+	pos int
+}
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// peek returns the next non space character or 0 at the end of input.
+func (p *exprParser) peek() byte {
+	p.skipSpaces()
+	if p.pos == len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *exprParser) parseSum() (exprNode, error) {
+	x, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return x, nil
+		}
+		p.pos++
+		y, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		x = exprBinary{op: op, x: x, y: y}
+	}
+}
+
+func (p *exprParser) parseProduct() (exprNode, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' {
+			return x, nil
+		}
+		p.pos++
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = exprBinary{op: op, x: x, y: y}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return exprUnary{x: x}, nil
+	case '+':
+		p.pos++
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, errors.New("unexpected end of expression")
+	case c == '(':
+		p.pos++
+		x, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ) at position %d", p.pos)
+		}
+		p.pos++
+		return x, nil
+	case c >= '0' && c <= '9', c == '.' && p.pos+1 < len(p.s) && isDigit(p.s[p.pos+1]):
+		return p.parseNumber()
+	case isIdentStart(rune(c)):
+		name := p.parseIdent()
+		if _, isFunc := exprFuncs[name]; isFunc && p.peek() == '(' {
+			return p.parseCall(name)
+		}
+		return exprField(name), nil
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", c, p.pos)
+}
+
+func (p *exprParser) parseNumber() (exprNode, error) {
+	start := p.pos
+	for p.pos < len(p.s) && (isDigit(p.s[p.pos]) || p.s[p.pos] == '.') {
+		p.pos++
+	}
+	if p.pos < len(p.s) && (p.s[p.pos] == 'e' || p.s[p.pos] == 'E') {
+		p.pos++
+		if p.pos < len(p.s) && (p.s[p.pos] == '+' || p.s[p.pos] == '-') {
+			p.pos++
+		}
+		for p.pos < len(p.s) && isDigit(p.s[p.pos]) {
+			p.pos++
+		}
+	}
+	v, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", p.s[start:p.pos])
+	}
+	return exprConst(v), nil
+}
+
+// parseIdent reads a field path. Label sets in braces are part of the path.
+func (p *exprParser) parseIdent() string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		switch {
+		case isIdentChar(rune(c)):
+			p.pos++
+		case c == '*' && (p.s[p.pos-1] == '.' || p.s[p.pos-1] == '*'):
+			p.pos++
+		case (c == '-' || c == '/') && isIdentChar(rune(p.s[p.pos-1])) && p.pos+1 < len(p.s) && isIdentChar(rune(p.s[p.pos+1])):
+			p.pos++
+		case c == '{':
+			end := strings.IndexByte(p.s[p.pos:], '}')
+			if end == -1 {
+				p.pos = len(p.s)
+			} else {
+				p.pos += end + 1
+			}
+		default:
+			return p.s[start:p.pos]
+		}
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *exprParser) parseCall(fn string) (exprNode, error) {
+	p.pos++ // (
+	var args []exprNode
+	for {
+		a, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+		c := p.peek()
+		p.pos++
+		if c == ')' {
+			break
+		}
+		if c != ',' {
+			return nil, fmt.Errorf("expected , or ) in %s call", fn)
+		}
+	}
+	if len(args) < exprFuncs[fn] {
+		return nil, fmt.Errorf("%s needs at least %d argument(s)", fn, exprFuncs[fn])
+	}
+	if fn == "abs" && len(args) != 1 {
+		return nil, errors.New("abs takes a single argument")
+	}
+	return exprCall{fn: fn, args: args}, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
+
+func isIdentChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == ':'
+}
@@ -0,0 +1,180 @@
+package data
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	vars := map[string]float64{
+		"a":           2,
+		"b":           3,
+		"c":           4,
+		"zero":        0,
+		"mem.heap":    10,
+		"mem.sys":     40,
+		"bytes-in":    7,
+		"req/s":       5,
+		"a:x":         1,
+		"b:x":         6,
+		`x{code="5"}`: 9,
+	}
+	tests := []struct {
+		expr   string
+		want   float64
+		fields []string
+	}{
+		// precedence and parentheses
+		{expr: "a + b * c", want: 14, fields: []string{"a", "b", "c"}},
+		{expr: "(a + b) * c", want: 20},
+		{expr: "a * b + c", want: 10},
+		{expr: "c - b - a", want: -1},
+		{expr: "c / a / a", want: 1},
+		{expr: "((a))", want: 2},
+		{expr: "mem.heap / mem.sys * 100", want: 25, fields: []string{"mem.heap", "mem.sys"}},
+		{expr: "1.5e2 + .5", want: 150.5},
+		// unary minus
+		{expr: "-a", want: -2},
+		{expr: "-a * -b", want: 6},
+		{expr: "c - -a", want: 6},
+		{expr: "-(a + b)", want: -5},
+		{expr: "+a", want: 2},
+		// functions
+		{expr: "abs(a - c)", want: 2},
+		{expr: "min(a, b, c)", want: 2},
+		{expr: "max(a, b, c)", want: 4},
+		{expr: "max(a)", want: 2},
+		{expr: "min(a, -b) * 2", want: -6},
+		// - and / between words are part of the path
+		{expr: "bytes-in", want: 7, fields: []string{"bytes-in"}},
+		{expr: "req/s", want: 5, fields: []string{"req/s"}},
+		{expr: "mem.sys - mem.heap", want: 30, fields: []string{"mem.sys", "mem.heap"}},
+		{expr: "mem.sys / mem.heap", want: 4},
+		{expr: "b:x - a:x", want: 5, fields: []string{"b:x", "a:x"}},
+		{expr: "a - 1", want: 1, fields: []string{"a"}},
+		{expr: "a-1", want: 0, fields: []string{"a-1"}},
+		{expr: `x{code="5"} * 2`, want: 18, fields: []string{`x{code="5"}`}},
+	}
+	for _, tt := range tests {
+		e, err := ParseExpr(tt.expr)
+		if err != nil {
+			t.Errorf("ParseExpr(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if tt.fields != nil && !reflect.DeepEqual(e.Fields(), tt.fields) {
+			t.Errorf("ParseExpr(%q).Fields() = %q, want %q", tt.expr, e.Fields(), tt.fields)
+		}
+		if got := e.root.eval(vars); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("ParseExpr(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"a +",
+		"(a + b",
+		"a + b)",
+		"a b",
+		"* a",
+		"abs(a, b)",
+		"abs()",
+		"min(a b)",
+		"max(a,",
+		"mem.*",
+		"1.2.3",
+	} {
+		if _, err := ParseExpr(expr); err == nil {
+			t.Errorf("ParseExpr(%q) = nil error, want an error", expr)
+		}
+	}
+}
+
+func TestExprIsField(t *testing.T) {
+	for expr, want := range map[string]bool{
+		"a":          true,
+		"mem.heap":   true,
+		"req/s":      true,
+		"a / b":      false,
+		"max(a, b)":  false,
+		"-a":         false,
+		"(mem.heap)": true,
+	} {
+		e, err := ParseExpr(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := e.IsField(); got != want {
+			t.Errorf("ParseExpr(%q).IsField() = %v, want %v", expr, got, want)
+		}
+	}
+}
+
+func TestExprEval(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		points  map[string]Points
+		want    Points
+		wantErr bool
+	}{
+		{
+			name: "same timestamps",
+			expr: "a / b",
+			points: map[string]Points{
+				"a": {{at(0), 10}, {at(1), 20}},
+				"b": {{at(0), 2}, {at(1), 4}},
+			},
+			want: Points{{at(0), 5}, {at(1), 5}},
+		},
+		{
+			name: "division by zero drops the point",
+			expr: "a / b",
+			points: map[string]Points{
+				"a": {{at(0), 10}, {at(1), 20}, {at(2), 0}},
+				"b": {{at(0), 0}, {at(1), 4}, {at(2), 0}},
+			},
+			want: Points{{at(1), 5}},
+		},
+		{
+			name: "latest value of each field",
+			expr: "a + b",
+			points: map[string]Points{
+				"a": {{at(0), 1}, {at(2), 3}},
+				"b": {{at(1), 10}, {at(3), 30}},
+			},
+			want: Points{{at(1), 11}, {at(2), 13}, {at(3), 33}},
+		},
+		{
+			name: "unsorted points",
+			expr: "-a",
+			points: map[string]Points{
+				"a": {{at(1), 2}, {at(0), 1}},
+			},
+			want: Points{{at(0), -1}, {at(1), -2}},
+		},
+		{
+			name:    "missing field",
+			expr:    "a + b",
+			points:  map[string]Points{"a": {{at(0), 1}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := ParseExpr(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := e.Eval(tt.points)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Eval() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !pointsEqual(got, tt.want) {
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
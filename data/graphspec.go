@@ -1,5 +1,10 @@
 package data
 
+import (
+	"regexp"
+	"strings"
+)
+
 type GraphSpec struct {
 	Fields []Field
 }
@@ -8,5 +13,60 @@ type Field struct {
 	ID      string
 	Name    string
 	Counter bool
-	Marker  bool
+	// Rate plots the per-second increase of a counter.
+	Rate bool
+	// Gauge forces the raw value to be plotted even if the source reports
+	// the field as a counter.
+	Gauge  bool
+	Marker bool
+	// Downsample is the method used to reduce the number of points to the
+	// width of the graph: "lttb", "minmax" or empty to draw every point.
+	Downsample string
+
+	// Matchers filters the labels of the series named Name.
+	Matchers []Matcher
+	// Aggregation combines the series matching the field with one of the
+	// Aggregations operators, grouped by the By labels, or by all labels
+	// but By if Without is true.
+	Aggregation string
+	By          []string
+	Without     bool
+
+	// Expr, when set, computes the field from other fields. Name is then the
+	// display name of the field.
+	Expr *Expr
+
+	// pattern is the compiled form of a Name with wildcards.
+	pattern *regexp.Regexp
+}
+
+// Dynamic tells if the field expands to a set of series known only once data
+// is received. Dynamic fields are resolved using Select and each resulting
+// series is stored in the DataSet under SeriesID.
+func (f Field) Dynamic() bool {
+	if f.Expr != nil {
+		return false
+	}
+	return len(f.Matchers) > 0 || f.Aggregation != "" || f.Wildcard()
+}
+
+// Wildcard tells if the field name contains * or ** wildcards.
+func (f Field) Wildcard() bool {
+	return strings.Contains(f.Name, "*")
+}
+
+// SetName sets the name of the field and compiles its wildcards if any.
+// Fields with wildcards must be named with SetName or built by ParseSelector
+// for their series to be matched.
+func (f *Field) SetName(name string) {
+	f.Name = name
+	f.pattern = nil
+	if f.Wildcard() {
+		f.pattern = globRegexp(name)
+	}
+}
+
+// SeriesID returns the DataSet name of one of the series of a dynamic field.
+func (f Field) SeriesID(name string) string {
+	return f.ID + "/" + name
 }
@@ -0,0 +1,437 @@
+package data
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Label is a name/value pair identifying a series within a metric.
+type Label struct {
+	Name, Value string
+}
+
+// Matcher filters series on the value of a label. Op is one of =, !=, =~
+// or !~; regular expressions are anchored as in Prometheus.
+type Matcher struct {
+	Name  string
+	Op    string
+	Value string
+	re    *regexp.Regexp
+}
+
+// Matches tells if value satisfies the matcher.
+func (m Matcher) Matches(value string) bool {
+	switch m.Op {
+	case "=":
+		return value == m.Value
+	case "!=":
+		return value != m.Value
+	case "=~":
+		return m.re.MatchString(value)
+	case "!~":
+		return !m.re.MatchString(value)
+	}
+	return false
+}
+
+// Aggregations lists the supported aggregation operators.
+var Aggregations = map[string]bool{"sum": true, "avg": true, "min": true, "max": true, "count": true}
+
+// SeriesName formats a metric name and its labels as name{a="1",b="2"}.
+// Labels are sorted by name.
+func SeriesName(name string, labels []Label) string {
+	if len(labels) == 0 {
+		return name
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+	var b bytes.Buffer
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.Name)
+		b.WriteString(`="`)
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(l.Value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// ParseSeriesName splits a series name as formatted by SeriesName into its
+// metric name and labels. Names without labels are returned as is.
+func ParseSeriesName(s string) (string, []Label, error) {
+	i := strings.IndexByte(s, '{')
+	if i == -1 {
+		return s, nil, nil
+	}
+	labels, rest, err := ParseLabels(s[i:])
+	if err != nil {
+		return "", nil, err
+	}
+	if strings.TrimSpace(rest) != "" {
+		return "", nil, fmt.Errorf("unexpected %q after labels", rest)
+	}
+	return s[:i], labels, nil
+}
+
+// ParseLabels parses a {name="value",...} label set at the start of s and
+// returns the remaining of s.
+func ParseLabels(s string) ([]Label, string, error) {
+	matchers, rest, err := parseLabelSet(s, false)
+	if err != nil {
+		return nil, "", err
+	}
+	labels := make([]Label, 0, len(matchers))
+	for _, m := range matchers {
+		labels = append(labels, Label{Name: m.Name, Value: m.Value})
+	}
+	return labels, rest, nil
+}
+
+// parseLabelSet parses a label set starting with an opening brace. If ops is
+// true, matcher operators other than = are accepted.
+func parseLabelSet(s string, ops bool) ([]Matcher, string, error) {
+	if s == "" || s[0] != '{' {
+		return nil, "", errors.New("expected {")
+	}
+	s = s[1:]
+	var matchers []Matcher
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return nil, "", errors.New("unterminated label set")
+		}
+		if s[0] == '}' {
+			return matchers, s[1:], nil
+		}
+		i := strings.IndexAny(s, "=!~")
+		if i <= 0 {
+			return nil, "", fmt.Errorf("invalid label: %s", s)
+		}
+		m := Matcher{Name: strings.TrimSpace(s[:i]), Op: "="}
+		s = s[i:]
+		for _, op := range []string{"=~", "!~", "!=", "="} {
+			if strings.HasPrefix(s, op) {
+				m.Op = op
+				s = s[len(op):]
+				break
+			}
+		}
+		if m.Op != "=" && !ops {
+			return nil, "", fmt.Errorf("invalid operator for label %s", m.Name)
+		}
+		s = strings.TrimLeft(s, " \t")
+		if s == "" || s[0] != '"' {
+			return nil, "", fmt.Errorf("invalid label value for %s", m.Name)
+		}
+		var value bytes.Buffer
+		i = 1
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i])
+				}
+				continue
+			}
+			value.WriteByte(s[i])
+		}
+		if i == len(s) {
+			return nil, "", fmt.Errorf("unterminated label value for %s", m.Name)
+		}
+		m.Value = value.String()
+		if m.Op == "=~" || m.Op == "!~" {
+			re, err := regexp.Compile("^(?:" + m.Value + ")$")
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid regexp for label %s: %v", m.Name, err)
+			}
+			m.re = re
+		}
+		matchers = append(matchers, m)
+		s = s[i+1:]
+	}
+}
+
+// ParseSelector parses a field selector. It accepts a metric name with
+// optional label matchers like http_requests_total{code=~"5.."}, optionally
+// wrapped in an aggregation like sum by (code)(http_requests_total). The
+// metric name may contain * and ** wildcards like handlers.*.count. The
+// returned field has its Name, Matchers, Aggregation, By and Without set.
+func ParseSelector(s string) (Field, error) {
+	var f Field
+	s = strings.TrimSpace(s)
+	if op := leadingWord(s); Aggregations[op] && strings.ContainsAny(s, "(") {
+		f.Aggregation = op
+		s = strings.TrimSpace(s[len(op):])
+		var err error
+		// The grouping clause may come before or after the parenthesis.
+		if s, err = f.parseGrouping(s); err != nil {
+			return f, err
+		}
+		if s == "" || s[0] != '(' {
+			return f, fmt.Errorf("expected ( after %s", op)
+		}
+		end := closingParen(s)
+		if end == -1 {
+			return f, errors.New("unterminated aggregation")
+		}
+		inner := s[1:end]
+		s = strings.TrimSpace(s[end+1:])
+		if len(f.By) == 0 && !f.Without {
+			if s, err = f.parseGrouping(s); err != nil {
+				return f, err
+			}
+		}
+		if s != "" {
+			return f, fmt.Errorf("unexpected %q", s)
+		}
+		s = strings.TrimSpace(inner)
+	}
+	i := strings.IndexByte(s, '{')
+	if i == -1 {
+		i = len(s)
+	} else {
+		matchers, rest, err := parseLabelSet(s[i:], true)
+		if err != nil {
+			return f, err
+		}
+		if strings.TrimSpace(rest) != "" {
+			return f, fmt.Errorf("unexpected %q after labels", rest)
+		}
+		f.Matchers = matchers
+	}
+	f.SetName(strings.TrimSpace(s[:i]))
+	return f, nil
+}
+
+// parseGrouping parses an optional by (a, b) or without (a, b) clause.
+func (f *Field) parseGrouping(s string) (string, error) {
+	word := leadingWord(s)
+	if word != "by" && word != "without" {
+		return s, nil
+	}
+	s = strings.TrimSpace(s[len(word):])
+	if s == "" || s[0] != '(' {
+		return "", fmt.Errorf("expected ( after %s", word)
+	}
+	end := strings.IndexByte(s, ')')
+	if end == -1 {
+		return "", fmt.Errorf("unterminated %s clause", word)
+	}
+	for _, l := range strings.Split(s[1:end], ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			f.By = append(f.By, l)
+		}
+	}
+	f.Without = word == "without"
+	return strings.TrimSpace(s[end+1:]), nil
+}
+
+// leadingWord returns the identifier at the start of s.
+func leadingWord(s string) string {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.' && r != ':'
+	})
+	if i == -1 {
+		return s
+	}
+	return s[:i]
+}
+
+// closingParen returns the index of the parenthesis closing the one at the
+// start of s, or -1 if not found.
+func closingParen(s string) int {
+	var depth int
+	var quoted bool
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Selection is a series selected by a dynamic field.
+type Selection struct {
+	// Name is the name of the series, also used as its display name.
+	Name   string
+	Points Points
+	// Sources lists the names of the series it has been computed from.
+	Sources []string
+}
+
+// Select returns the series of points matching the field, sorted by name.
+// Aggregated fields return one series per group with points combined by
+// timestamp. If transform is not nil, it is applied to the points of each
+// matching series before they are combined, like to aggregate the increase
+// of counters rather than their raw values.
+func (f Field) Select(points map[string]Points, transform func(name string, pts Points) Points) []Selection {
+	groups := map[string]*Selection{}
+	members := map[string][]Points{}
+	for name, pts := range points {
+		metric, labels, err := ParseSeriesName(name)
+		if err != nil || !f.matchName(metric) || !f.matches(labels) {
+			continue
+		}
+		key := name
+		if f.Aggregation != "" {
+			key = f.groupName(labels)
+		}
+		g := groups[key]
+		if g == nil {
+			g = &Selection{Name: key}
+			groups[key] = g
+		}
+		g.Sources = append(g.Sources, name)
+		if transform != nil {
+			pts = transform(name, pts)
+		}
+		members[key] = append(members[key], pts)
+	}
+	sel := make([]Selection, 0, len(groups))
+	for key, g := range groups {
+		if f.Aggregation == "" {
+			g.Points = members[key][0]
+		} else {
+			g.Points = aggregate(f.Aggregation, members[key])
+		}
+		sort.Strings(g.Sources)
+		sel = append(sel, *g)
+	}
+	sort.Slice(sel, func(i, j int) bool {
+		return sel[i].Name < sel[j].Name
+	})
+	return sel
+}
+
+// matchName tells if the metric name matches the field name, expanding
+// wildcards if any.
+func (f Field) matchName(name string) bool {
+	if f.pattern == nil {
+		return name == f.Name
+	}
+	return f.pattern.MatchString(name)
+}
+
+// globRegexp converts a field path with wildcards to a regexp. A * matches
+// any character but a dot, ** matches anything including dots.
+func globRegexp(glob string) *regexp.Regexp {
+	var b bytes.Buffer
+	b.WriteByte('^')
+	for i, part := range strings.Split(glob, "**") {
+		if i > 0 {
+			b.WriteString(".*")
+		}
+		for j, p := range strings.Split(part, "*") {
+			if j > 0 {
+				b.WriteString("[^.]*")
+			}
+			b.WriteString(regexp.QuoteMeta(p))
+		}
+	}
+	b.WriteByte('$')
+	return regexp.MustCompile(b.String())
+}
+
+func (f Field) matches(labels []Label) bool {
+	for _, m := range f.Matchers {
+		var value string
+		for _, l := range labels {
+			if l.Name == m.Name {
+				value = l.Value
+				break
+			}
+		}
+		if !m.Matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// groupName returns the name of the aggregation group the labels belong to.
+func (f Field) groupName(labels []Label) string {
+	var group []Label
+	for _, l := range labels {
+		var listed bool
+		for _, b := range f.By {
+			if l.Name == b {
+				listed = true
+				break
+			}
+		}
+		if listed != f.Without {
+			group = append(group, l)
+		}
+	}
+	if len(group) == 0 {
+		return fmt.Sprintf("%s(%s)", f.Aggregation, f.Name)
+	}
+	return SeriesName(f.Name, group)
+}
+
+// aggregate combines the points of several series sharing the same
+// timestamps.
+func aggregate(op string, series []Points) Points {
+	type acc struct {
+		p     Point
+		count int
+	}
+	byTime := map[int64]*acc{}
+	for _, pts := range series {
+		for _, p := range pts {
+			k := p.Timestamp.UnixNano()
+			a := byTime[k]
+			if a == nil {
+				byTime[k] = &acc{p: p, count: 1}
+				continue
+			}
+			switch op {
+			case "sum", "avg":
+				a.p.Value += p.Value
+			case "min":
+				a.p.Value = math.Min(a.p.Value, p.Value)
+			case "max":
+				a.p.Value = math.Max(a.p.Value, p.Value)
+			}
+			a.count++
+		}
+	}
+	res := make(Points, 0, len(byTime))
+	for _, a := range byTime {
+		switch op {
+		case "avg":
+			a.p.Value /= float64(a.count)
+		case "count":
+			a.p.Value = float64(a.count)
+		}
+		res = append(res, a.p)
+	}
+	sort.Sort(res)
+	return res
+}
@@ -0,0 +1,209 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Field
+		wantErr bool
+	}{
+		{in: "x", want: Field{Name: "x"}},
+		{in: "sum by (code)(x)", want: Field{Name: "x", Aggregation: "sum", By: []string{"code"}}},
+		{in: "sum(x) by (m)", want: Field{Name: "x", Aggregation: "sum", By: []string{"m"}}},
+		{in: "avg by (a, b) (x)", want: Field{Name: "x", Aggregation: "avg", By: []string{"a", "b"}}},
+		{in: "max without (instance)(x)", want: Field{Name: "x", Aggregation: "max", By: []string{"instance"}, Without: true}},
+		{in: "count(x) without (a)", want: Field{Name: "x", Aggregation: "count", By: []string{"a"}, Without: true}},
+		{in: "min(x)", want: Field{Name: "x", Aggregation: "min"}},
+		{in: `x{code="200"}`, want: Field{Name: "x", Matchers: []Matcher{{Name: "code", Op: "=", Value: "200"}}}},
+		{in: `x{code!="200", m="GET"}`, want: Field{Name: "x", Matchers: []Matcher{
+			{Name: "code", Op: "!=", Value: "200"},
+			{Name: "m", Op: "=", Value: "GET"},
+		}}},
+		{in: `x{path="/a,b}:c"}`, want: Field{Name: "x", Matchers: []Matcher{{Name: "path", Op: "=", Value: "/a,b}:c"}}}},
+		{in: `x{q="say \"hi\"\n"}`, want: Field{Name: "x", Matchers: []Matcher{{Name: "q", Op: "=", Value: "say \"hi\"\n"}}}},
+		{in: `sum by (code)(x{path=")"})`, want: Field{Name: "x", Aggregation: "sum", By: []string{"code"},
+			Matchers: []Matcher{{Name: "path", Op: "=", Value: ")"}}}},
+		{in: "sum", want: Field{Name: "sum"}},
+		{in: "sum by code(x)", wantErr: true},
+		{in: "sum by (code(x)", wantErr: true},
+		{in: "sum(x", wantErr: true},
+		{in: "sum(x) foo", wantErr: true},
+		{in: "sum by (a)", wantErr: true},
+		{in: `x{a="1"`, wantErr: true},
+		{in: `x{a=1}`, wantErr: true},
+		{in: `x{="1"}`, wantErr: true},
+		{in: `x{a="1}`, wantErr: true},
+		{in: `x{a=~"("}`, wantErr: true},
+		{in: `x{a="1"} y`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSelector(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSelector(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		// regexps are checked by TestMatcher
+		for i := range got.Matchers {
+			got.Matchers[i].re = nil
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSelector(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		in    string
+		value string
+		want  bool
+	}{
+		{`x{code="200"}`, "200", true},
+		{`x{code="200"}`, "2000", false},
+		{`x{code!="200"}`, "500", true},
+		{`x{code!="200"}`, "200", false},
+		{`x{code=~"5.."}`, "503", true},
+		{`x{code=~"5.."}`, "5030", false}, // anchored
+		{`x{code=~"5..|404"}`, "404", true},
+		{`x{code!~"2.."}`, "200", false},
+		{`x{code!~"2.."}`, "503", true},
+		{`x{code=""}`, "", true},
+	}
+	for _, tt := range tests {
+		f, err := ParseSelector(tt.in)
+		if err != nil {
+			t.Fatalf("ParseSelector(%q): %v", tt.in, err)
+		}
+		if got := f.Matchers[0].Matches(tt.value); got != tt.want {
+			t.Errorf("%s matches %q = %v, want %v", tt.in, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, rest, err := ParseLabels(`{a="1,2", b="}", c="x:y",d="q\"\\"} 42`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Label{{"a", "1,2"}, {"b", "}"}, {"c", "x:y"}, {"d", `q"\`}}
+	if !reflect.DeepEqual(labels, want) || rest != " 42" {
+		t.Errorf("ParseLabels() = %v, %q, want %v, %q", labels, rest, want, " 42")
+	}
+	if labels, _, err := ParseLabels("{}"); err != nil || len(labels) != 0 {
+		t.Errorf(`ParseLabels("{}") = %v, %v, want no labels`, labels, err)
+	}
+	for _, in := range []string{``, `a="1"`, `{a=~"1"}`, `{a!="1"}`, `{a="1"`, `{a}`} {
+		if _, _, err := ParseLabels(in); err == nil {
+			t.Errorf("ParseLabels(%q) = nil error, want an error", in)
+		}
+	}
+}
+
+func TestSeriesName(t *testing.T) {
+	labels := []Label{{"path", "/a,b}"}, {"code", `"5"`}}
+	name := SeriesName("x", labels)
+	if want := `x{code="\"5\"",path="/a,b}"}`; name != want {
+		t.Errorf("SeriesName() = %s, want %s", name, want)
+	}
+	metric, got, err := ParseSeriesName(name)
+	if err != nil || metric != "x" || !reflect.DeepEqual(got, labels) {
+		t.Errorf("ParseSeriesName(%s) = %s, %v, %v, want x, %v", name, metric, got, err, labels)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	points := map[string]Points{
+		`req{code="200",m="GET"}`:  seconds(0, 1),
+		`req{code="200",m="POST"}`: {{at(0), 2}, {at(1), 3}},
+		`req{code="500",m="GET"}`:  {{at(1), 10}},
+		`other{code="200"}`:        seconds(0),
+		"handlers.a.count":         seconds(0),
+		"handlers.b.count":         seconds(0),
+		"handlers.b.c.count":       seconds(0),
+	}
+	parse := func(s string) Field {
+		f, err := ParseSelector(s)
+		if err != nil {
+			t.Fatalf("ParseSelector(%q): %v", s, err)
+		}
+		return f
+	}
+	tests := []struct {
+		field Field
+		want  []Selection
+	}{
+		{parse(`req{code="200"}`), []Selection{
+			{`req{code="200",m="GET"}`, seconds(0, 1), []string{`req{code="200",m="GET"}`}},
+			{`req{code="200",m="POST"}`, Points{{at(0), 2}, {at(1), 3}}, []string{`req{code="200",m="POST"}`}},
+		}},
+		{parse(`req{code=~"5.."}`), []Selection{
+			{`req{code="500",m="GET"}`, Points{{at(1), 10}}, []string{`req{code="500",m="GET"}`}},
+		}},
+		{parse("sum by (code)(req)"), []Selection{
+			{`req{code="200"}`, Points{{at(0), 2}, {at(1), 4}}, []string{`req{code="200",m="GET"}`, `req{code="200",m="POST"}`}},
+			{`req{code="500"}`, Points{{at(1), 10}}, []string{`req{code="500",m="GET"}`}},
+		}},
+		{parse("max(req) without (code)"), []Selection{
+			{`req{m="GET"}`, Points{{at(0), 0}, {at(1), 10}}, []string{`req{code="200",m="GET"}`, `req{code="500",m="GET"}`}},
+			{`req{m="POST"}`, Points{{at(0), 2}, {at(1), 3}}, []string{`req{code="200",m="POST"}`}},
+		}},
+		{parse(`count(req{m="GET"})`), []Selection{
+			{"count(req)", Points{{at(0), 1}, {at(1), 2}}, []string{`req{code="200",m="GET"}`, `req{code="500",m="GET"}`}},
+		}},
+		{parse("handlers.*.count"), []Selection{
+			{"handlers.a.count", seconds(0), []string{"handlers.a.count"}},
+			{"handlers.b.count", seconds(0), []string{"handlers.b.count"}},
+		}},
+		{parse("sum(handlers.**)"), []Selection{
+			{"sum(handlers.**)", Points{{at(0), 0}}, []string{"handlers.a.count", "handlers.b.c.count", "handlers.b.count"}},
+		}},
+	}
+	for _, tt := range tests {
+		got := tt.field.Select(points, nil)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: Select() = %v, want %v", tt.field.Name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i].Name != tt.want[i].Name || !pointsEqual(got[i].Points, tt.want[i].Points) ||
+				!reflect.DeepEqual(got[i].Sources, tt.want[i].Sources) {
+				t.Errorf("%s: Select()[%d] = %v, want %v", tt.field.Name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestSelectTransform(t *testing.T) {
+	points := map[string]Points{
+		`c{a="1"}`: seconds(0, 1),
+		`c{a="2"}`: seconds(0, 1),
+	}
+	f, _ := ParseSelector("sum(c)")
+	var names []string
+	got := f.Select(points, func(name string, pts Points) Points {
+		names = append(names, name)
+		return Points{{pts[len(pts)-1].Timestamp, 1}}
+	})
+	if len(got) != 1 || !pointsEqual(got[0].Points, Points{{at(1), 2}}) || len(names) != 2 {
+		t.Errorf("Select() = %v with transforms of %v, want the transformed points summed", got, names)
+	}
+}
+
+func TestFieldSetName(t *testing.T) {
+	f, _ := ParseSelector("src:handlers.*")
+	f.SetName("handlers.*")
+	if !f.matchName("handlers.a") || f.matchName("src:handlers.a") {
+		t.Error("SetName() did not recompile the wildcards of the field")
+	}
+	f.SetName("handlers.a")
+	if !f.matchName("handlers.a") || f.matchName("handlers.b") {
+		t.Error("SetName() kept the wildcards of the previous name")
+	}
+}
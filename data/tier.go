@@ -0,0 +1,127 @@
+package data
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tier is a retention level of a DataSet: points are kept for Retention,
+// downsampled to one bucket per Resolution. A zero Resolution keeps raw
+// points.
+type Tier struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+// DefaultTiers keeps raw points for 10 minutes, 10s buckets for 6 hours and
+// 1m buckets for a week.
+var DefaultTiers = []Tier{
+	{Resolution: 0, Retention: 10 * time.Minute},
+	{Resolution: 10 * time.Second, Retention: 6 * time.Hour},
+	{Resolution: time.Minute, Retention: 7 * 24 * time.Hour},
+}
+
+// ParseTiers parses a list of tiers like raw:10m,10s:6h,1m:7d. The first
+// tier must be raw and resolutions must increase. The "default" string
+// returns DefaultTiers.
+func ParseTiers(s string) ([]Tier, error) {
+	if s == "default" {
+		return DefaultTiers, nil
+	}
+	var tiers []Tier
+	for i, t := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(t), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid tier %q: expected resolution:retention", t)
+		}
+		var tier Tier
+		var err error
+		if parts[0] != "raw" {
+			if tier.Resolution, err = parseDays(parts[0]); err != nil {
+				return nil, fmt.Errorf("invalid tier %q: %v", t, err)
+			}
+		}
+		if tier.Retention, err = parseDays(parts[1]); err != nil {
+			return nil, fmt.Errorf("invalid tier %q: %v", t, err)
+		}
+		switch {
+		case i == 0 && tier.Resolution != 0:
+			return nil, fmt.Errorf("invalid tier %q: first tier must be raw", t)
+		case i > 0 && tier.Resolution <= tiers[i-1].Resolution:
+			return nil, fmt.Errorf("invalid tier %q: resolution must be greater than the previous tier", t)
+		case tier.Retention <= 0:
+			return nil, fmt.Errorf("invalid tier %q: retention must be positive", t)
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers, nil
+}
+
+// parseDays parses a duration, also accepting a number of days like 7d.
+func parseDays(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		d, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(d * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+// bucketSet stores the min, max and average of the points pushed to a
+// series for each Resolution interval of a tier. The bucket being filled is
+// stored as well and updated in place.
+type bucketSet struct {
+	Tier
+	avg, min, max SortedPointSet
+
+	// open bucket
+	start  time.Time
+	last   time.Time
+	sum    float64
+	count  int
+	lo, hi float64
+}
+
+func newBucketSet(t Tier) *bucketSet {
+	size := int(t.Retention/t.Resolution) + 1
+	return &bucketSet{
+		Tier: t,
+		avg:  SortedPointSet{Size: size, MaxAge: t.Retention},
+		min:  SortedPointSet{Size: size, MaxAge: t.Retention},
+		max:  SortedPointSet{Size: size, MaxAge: t.Retention},
+	}
+}
+
+// add accounts p in its bucket. Points not newer than the previous one are
+// ignored as they have been accounted for already or belong to a closed
+// bucket. Gaps are ignored too, buckets being made of the available values.
+func (b *bucketSet) add(p Point) {
+	if p.IsGap() {
+		return
+	}
+	if b.count > 0 && !b.last.Before(p.Timestamp) {
+		return
+	}
+	b.last = p.Timestamp
+	start := p.Timestamp.Truncate(b.Resolution)
+	if b.count == 0 || start.After(b.start) {
+		b.start = start
+		b.sum, b.count = 0, 0
+		b.lo, b.hi = p.Value, p.Value
+	}
+	b.sum += p.Value
+	b.count++
+	if p.Value < b.lo {
+		b.lo = p.Value
+	}
+	if p.Value > b.hi {
+		b.hi = p.Value
+	}
+	b.avg.Add(Point{Timestamp: b.start, Value: b.sum / float64(b.count)})
+	b.min.Add(Point{Timestamp: b.start, Value: b.lo})
+	b.max.Add(Point{Timestamp: b.start, Value: b.hi})
+}
@@ -0,0 +1,159 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTiers(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []Tier
+		wantErr bool
+	}{
+		{in: "default", want: DefaultTiers},
+		{in: "raw:10m", want: []Tier{{0, 10 * time.Minute}}},
+		{in: "raw:10m,10s:6h,1m:7d", want: []Tier{
+			{0, 10 * time.Minute},
+			{10 * time.Second, 6 * time.Hour},
+			{time.Minute, 7 * 24 * time.Hour},
+		}},
+		{in: " raw:1h , 1m:1.5d", want: []Tier{{0, time.Hour}, {time.Minute, 36 * time.Hour}}},
+		{in: "", wantErr: true},
+		{in: "raw", wantErr: true},
+		{in: "raw:10m:1h", wantErr: true},
+		{in: "10s:1h", wantErr: true},
+		{in: "raw:10x", wantErr: true},
+		{in: "raw:10m,abc:1h", wantErr: true},
+		{in: "raw:10m,10s:xd", wantErr: true},
+		{in: "raw:0s", wantErr: true},
+		{in: "raw:10m,10s:-1h", wantErr: true},
+		{in: "raw:10m,raw:1h", wantErr: true},
+		{in: "raw:10m,1m:1h,10s:6h", wantErr: true},
+		{in: "raw:10m,1m:1h,1m:6h", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTiers(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTiers(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTiers(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// aligned returns the time sec seconds after the minute of epoch, so that
+// points fall in buckets in a known way.
+func aligned(sec float64) time.Time {
+	return epoch.Truncate(time.Minute).Add(time.Duration(sec * float64(time.Second)))
+}
+
+func TestBucketSet(t *testing.T) {
+	b := newBucketSet(Tier{Resolution: 10 * time.Second, Retention: time.Minute})
+	for _, p := range []Point{
+		{aligned(0), 4}, {aligned(3), 2}, {aligned(7), 6},
+		Gap(aligned(8)),   // ignored
+		{aligned(5), 100}, // late, ignored
+		{aligned(12), 1},  // next bucket
+		{aligned(12), 50}, // same time, ignored
+		{aligned(25), -3}, {aligned(29), 3},
+	} {
+		b.add(p)
+	}
+	want := map[string]Points{
+		"avg": {{aligned(0), 4}, {aligned(10), 1}, {aligned(20), 0}},
+		"min": {{aligned(0), 2}, {aligned(10), 1}, {aligned(20), -3}},
+		"max": {{aligned(0), 6}, {aligned(10), 1}, {aligned(20), 3}},
+	}
+	got := map[string]Points{
+		"avg": b.avg.Points(),
+		"min": b.min.Points(),
+		"max": b.max.Points(),
+	}
+	for k := range want {
+		if !pointsEqual(got[k], want[k]) {
+			t.Errorf("%s = %v, want %v", k, got[k], want[k])
+		}
+	}
+
+	// buckets older than the retention are dropped
+	b.add(Point{aligned(85), 1})
+	if got, want := b.avg.Points(), (Points{{aligned(20), 0}, {aligned(80), 1}}); !pointsEqual(got, want) {
+		t.Errorf("avg after expiry = %v, want %v", got, want)
+	}
+}
+
+func TestDataSetTiers(t *testing.T) {
+	ds := &DataSet{
+		Tiers: []Tier{
+			{0, time.Minute},
+			{10 * time.Second, 10 * time.Minute},
+			{time.Minute, time.Hour},
+		},
+		ExpectedFrequency: time.Second,
+	}
+	// a point per second for 30 minutes, the value being the minute
+	for i := 0; i < 1800; i++ {
+		ds.Push("x", aligned(float64(i)), float64(i/60), Gauge)
+	}
+
+	// raw points roll over into the next tiers
+	if n := len(ds.Get("x")); n != 61 {
+		t.Errorf("%d raw points, want the last minute", n)
+	}
+
+	tests := []struct {
+		name       string
+		span       time.Duration
+		width      int
+		resolution time.Duration // 0 for raw points
+		n          int
+	}{
+		{"raw fits", 30 * time.Second, 100, 0, 31},
+		{"raw too wide", 30 * time.Second, 10, 10 * time.Second, 4},
+		{"beyond raw", 5 * time.Minute, 100, 10 * time.Second, 31},
+		{"finer tier too wide", 5 * time.Minute, 20, time.Minute, 6},
+		{"beyond the finer tiers", 20 * time.Minute, 100, time.Minute, 21},
+		{"beyond all tiers", 2 * time.Hour, 100, time.Minute, 30},
+		{"all points", 0, 0, 0, 61},
+		{"no width", 30 * time.Second, 0, 0, 31},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			avg, min, max := ds.GetRange("x", tt.span, tt.width)
+			if len(avg) != tt.n {
+				t.Errorf("%d points, want %d", len(avg), tt.n)
+			}
+			if tt.resolution == 0 {
+				if min != nil || max != nil {
+					t.Error("min and max of raw points, want nil")
+				}
+				return
+			}
+			if len(min) != len(avg) || len(max) != len(avg) {
+				t.Fatalf("%d min and %d max points, want %d", len(min), len(max), len(avg))
+			}
+			for i := 1; i < len(avg); i++ {
+				if d := avg[i].Timestamp.Sub(avg[i-1].Timestamp); d != tt.resolution {
+					t.Fatalf("points %v apart, want %v", d, tt.resolution)
+				}
+			}
+			for i := range avg {
+				if min[i].Value > avg[i].Value || max[i].Value < avg[i].Value {
+					t.Errorf("bucket %v: min %v, avg %v, max %v", avg[i].Timestamp, min[i].Value, avg[i].Value, max[i].Value)
+				}
+			}
+		})
+	}
+
+	// a bucket of a minute holds the values of that minute
+	avg, _, _ := ds.GetRange("x", 2*time.Hour, 100)
+	for _, p := range avg {
+		if want := float64(p.Timestamp.Sub(aligned(0)) / time.Minute); p.Value != want {
+			t.Errorf("bucket %v = %v, want %v", p.Timestamp, p.Value, want)
+		}
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"os"
 
 	"github.com/rs/jplot/cmd"
-	"github.com/rs/jplot/window"
 )
 
 func fatalIf(err error, msg string) {
@@ -17,10 +16,5 @@ func fatalIf(err error, msg string) {
 }
 
 func main() {
-	if _, _, err := window.Size(); err != nil {
-		log.Fatal(fmt.Sprintf("Cannot get window size error=%v", err))
-		os.Exit(1)
-	}
-
 	cmd.Execute()
 }
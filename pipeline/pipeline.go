@@ -0,0 +1,308 @@
+// Package pipeline feeds the values of a source into a DataSet and renders
+// the graphs on a regular basis.
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/jplot/data"
+	"github.com/rs/jplot/source"
+	"github.com/rs/jplot/window"
+)
+
+// Screen is implemented by renderers which need to prepare the terminal
+// before the first frame and to restore it after the last one.
+type Screen interface {
+	Clear()
+	Restore()
+}
+
+// LastFrameRenderer is implemented by renderers drawing the last frame
+// differently than the periodic ones.
+type LastFrameRenderer interface {
+	RenderLast(specs []data.GraphSpec, ds *data.DataSet) error
+}
+
+// Pipeline fetches values from Source into DataSet and renders the graphs of
+// Specs every Interval, once the first values are received.
+type Pipeline struct {
+	Source   source.Getter
+	Specs    []data.GraphSpec
+	DataSet  *data.DataSet
+	Renderer window.Renderer
+	// Interval is the time between two frames.
+	Interval time.Duration
+	// MaxSeries is the maximum number of series of a dynamic field, 0 for no
+	// limit.
+	MaxSeries int
+	// MaxFailures is the number of consecutive failures of Source after
+	// which Run returns an error, 0 for no limit.
+	MaxFailures int
+	// Status, if set, is called with a message describing the failures of
+	// Source, and with an empty one once it recovers.
+	Status func(msg string)
+}
+
+// New creates a pipeline rendering specs with r every second.
+func New(s source.Getter, specs []data.GraphSpec, ds *data.DataSet, r window.Renderer) *Pipeline {
+	return &Pipeline{
+		Source:   s,
+		Specs:    specs,
+		DataSet:  ds,
+		Renderer: r,
+		Interval: time.Second,
+	}
+}
+
+// Run fetches values and renders them until the source is exhausted, ctx is
+// canceled, or an error occurs. A last frame is rendered before returning.
+// An error is returned if rendering fails, if the source returns a
+// source.FatalError, or if it fails more than MaxFailures times in a row.
+func (p *Pipeline) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ready := NewAtomicReady(false)
+	exit := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		err := p.render(ready, exit)
+		if err != nil {
+			// stop fetching
+			cancel()
+		}
+		done <- err
+	}()
+	err := p.fetch(ctx, ready)
+	close(exit)
+	if rerr := <-done; rerr != nil {
+		err = rerr
+	}
+	return err
+}
+
+// render renders a frame every Interval until exit is closed, then a last
+// frame.
+func (p *Pipeline) render(ready *Ready, exit chan struct{}) error {
+	if s, ok := p.Renderer.(Screen); ok {
+		s.Clear()
+		defer s.Restore()
+	}
+	t := time.NewTicker(p.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if ready.Ready() {
+				if err := p.Renderer.Render(p.Specs, p.DataSet); err != nil {
+					return fmt.Errorf("cannot render: %v", err)
+				}
+			}
+		case <-exit:
+			if !ready.Ready() {
+				return nil
+			}
+			var err error
+			if r, ok := p.Renderer.(LastFrameRenderer); ok {
+				err = r.RenderLast(p.Specs, p.DataSet)
+			} else {
+				err = p.Renderer.Render(p.Specs, p.DataSet)
+			}
+			if err != nil {
+				return fmt.Errorf("cannot render: %v", err)
+			}
+			return nil
+		}
+	}
+}
+
+// fetch pushes the results of Source to DataSet until the source is
+// exhausted or ctx is canceled.
+func (p *Pipeline) fetch(ctx context.Context, ready *Ready) error {
+	var failures int
+	// status is the message shown about the failures of the source.
+	var status string
+	for {
+		result, err := p.Source.Get(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if _, fatal := err.(source.FatalError); fatal {
+			return fmt.Errorf("input error: %v", err)
+		}
+		if err != nil {
+			failures++
+			if p.MaxFailures > 0 && failures >= p.MaxFailures {
+				return fmt.Errorf("input error: %v (%d consecutive failures)", err, failures)
+			}
+			status = fmt.Sprintf("Input error: %v (%d consecutive failures)", err, failures)
+			p.status(status)
+			if ready.Ready() {
+				// no gap before the first values
+				p.pushGaps(time.Now())
+			}
+			continue
+		}
+		failures = 0
+		var msg string
+		if result != nil && len(result.Failures) > 0 {
+			// some of merged sources failed, their fields get gaps
+			msg = fmt.Sprintf("Input error: %v", joinErrors(result.Failures))
+		}
+		if msg != status {
+			status = msg
+			p.status(status)
+		}
+		if result == nil {
+			// exhausted
+			return nil
+		}
+		p.push(result)
+		ready.MarkReady()
+	}
+}
+
+// joinErrors formats errs separated with semicolons.
+func joinErrors(errs []error) string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (p *Pipeline) status(msg string) {
+	if p.Status != nil {
+		p.Status(msg)
+	}
+}
+
+// push stores the points of result into DataSet for every field of Specs.
+// Fields are looked up by name. Fields missing from the result, as well as
+// series of dynamic fields no longer selected, get a gap. Dynamic fields get
+// at most MaxSeries series.
+func (p *Pipeline) push(result *source.Result) {
+	ds := p.DataSet
+	ts := resultTime(result)
+	for _, gs := range p.Specs {
+		for _, f := range gs.Fields {
+			if f.Expr != nil {
+				v, err := f.Expr.Eval(result.DataPoints)
+				if err != nil {
+					// a field of the expression is missing
+					ds.PushGap(f.ID, ts)
+					continue
+				}
+				ds.PushPoints(f.ID, v, fieldMode(f, nil))
+				continue
+			}
+			if f.Dynamic() {
+				ids, _ := ds.Series(f)
+				known := make(map[string]bool, len(ids))
+				for _, id := range ids {
+					known[id] = true
+				}
+				selected := make(map[string]bool, len(ids))
+				transform := p.seriesTransform(f, result)
+				for _, sel := range f.Select(result.DataPoints, transform) {
+					id := f.SeriesID(sel.Name)
+					if !known[id] {
+						if p.MaxSeries > 0 && len(known) >= p.MaxSeries {
+							continue
+						}
+						known[id] = true
+					}
+					selected[id] = true
+					mode := fieldMode(f, result, sel.Sources...)
+					if transform != nil {
+						// counters already turned into increases
+						mode = data.Gauge
+					}
+					ds.PushPoints(id, sel.Points, mode)
+				}
+				for _, id := range ids {
+					if !selected[id] {
+						ds.PushGap(id, ts)
+					}
+				}
+				continue
+			}
+			v, ok := result.DataPoints[f.Name]
+			if !ok {
+				ds.PushGap(f.ID, ts)
+				continue
+			}
+			ds.PushPoints(f.ID, v, fieldMode(f, result, f.Name))
+		}
+	}
+}
+
+// seriesTransform returns the function computing the increase of each
+// counter series of an aggregated field before they are combined, as
+// combining raw counters would see a series leaving the group as a counter
+// reset. It returns nil for fields not aggregated, or counted.
+func (p *Pipeline) seriesTransform(f data.Field, result *source.Result) func(string, data.Points) data.Points {
+	if f.Aggregation == "" || f.Aggregation == "count" {
+		return nil
+	}
+	return func(name string, points data.Points) data.Points {
+		mode := fieldMode(f, result, name)
+		if mode == data.Gauge {
+			return points
+		}
+		return p.DataSet.Transform(f.ID+"|"+name, points, mode)
+	}
+}
+
+// pushGaps adds a gap at ts to every series of Specs, like when the source
+// failed.
+func (p *Pipeline) pushGaps(ts time.Time) {
+	for _, gs := range p.Specs {
+		for _, f := range gs.Fields {
+			ids, _ := p.DataSet.Series(f)
+			for _, id := range ids {
+				p.DataSet.PushGap(id, ts)
+			}
+		}
+	}
+}
+
+// resultTime returns the time of the most recent point of result, or the
+// current time if it has none.
+func resultTime(result *source.Result) time.Time {
+	var ts time.Time
+	for _, points := range result.DataPoints {
+		for _, p := range points {
+			if p.Timestamp.After(ts) {
+				ts = p.Timestamp
+			}
+		}
+	}
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return ts
+}
+
+// fieldMode returns how the values of the field must be stored. Fields are
+// handled as counters if the user said so or if the source reports all the
+// series they are computed from as counters.
+func fieldMode(f data.Field, result *source.Result, names ...string) data.Mode {
+	if f.Rate {
+		return data.Rate
+	}
+	if f.Counter {
+		return data.Counter
+	}
+	if f.Gauge || result == nil || len(names) == 0 {
+		return data.Gauge
+	}
+	for _, name := range names {
+		if !result.Counters[name] {
+			return data.Gauge
+		}
+	}
+	return data.Counter
+}
@@ -0,0 +1,256 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/jplot/data"
+	"github.com/rs/jplot/source"
+)
+
+// step is a scripted response of fakeSource.
+type step struct {
+	result *source.Result
+	err    error
+	// before, if set, is called before responding.
+	before func()
+}
+
+// fakeSource returns its steps in order, then nil results as an exhausted
+// source.
+type fakeSource struct {
+	steps []step
+	mu    sync.Mutex
+	gets  int
+}
+
+func (s *fakeSource) Get(ctx context.Context) (*source.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gets++
+	if len(s.steps) == 0 {
+		return nil, nil
+	}
+	st := s.steps[0]
+	s.steps = s.steps[1:]
+	if st.before != nil {
+		st.before()
+	}
+	return st.result, st.err
+}
+
+func (s *fakeSource) Close() error {
+	return nil
+}
+
+func (s *fakeSource) Gets() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gets
+}
+
+// frame is a render recorded by fakeRenderer.
+type frame struct {
+	last   bool
+	points int
+}
+
+// fakeRenderer records the frames rendered with the number of points of the
+// series x at that time.
+type fakeRenderer struct {
+	mu     sync.Mutex
+	frames []frame
+}
+
+func (r *fakeRenderer) Render(specs []data.GraphSpec, ds *data.DataSet) error {
+	r.record(ds, false)
+	return nil
+}
+
+func (r *fakeRenderer) RenderLast(specs []data.GraphSpec, ds *data.DataSet) error {
+	r.record(ds, true)
+	return nil
+}
+
+func (r *fakeRenderer) record(ds *data.DataSet, last bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frames = append(r.frames, frame{last: last, points: len(ds.Get("x"))})
+}
+
+var epoch = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// values returns a result with a point at sec seconds after epoch for every
+// name and value pair of kv.
+func values(sec int, kv ...interface{}) *source.Result {
+	ts := epoch.Add(time.Duration(sec) * time.Second)
+	r := &source.Result{DataPoints: map[string]data.Points{}}
+	for i := 0; i < len(kv); i += 2 {
+		r.DataPoints[kv[i].(string)] = data.Points{{Timestamp: ts, Value: kv[i+1].(float64)}}
+	}
+	return r
+}
+
+func newTestPipeline(s source.Getter, r *fakeRenderer, fields ...data.Field) *Pipeline {
+	specs := []data.GraphSpec{{Fields: fields}}
+	p := New(s, specs, &data.DataSet{Size: 100}, r)
+	p.Interval = time.Hour
+	return p
+}
+
+func TestRunLastFrame(t *testing.T) {
+	s := &fakeSource{steps: []step{
+		{result: values(0, "x", 1.0)},
+		{result: values(1, "x", 2.0)},
+		{result: values(2, "x", 3.0)},
+	}}
+	r := &fakeRenderer{}
+	p := newTestPipeline(s, r, data.Field{ID: "x", Name: "x"})
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []frame{{last: true, points: 3}}
+	if len(r.frames) != 1 || r.frames[0] != want[0] {
+		t.Errorf("frames = %v, want %v", r.frames, want)
+	}
+}
+
+func TestRunNoRenderBeforeResult(t *testing.T) {
+	failure := step{err: errors.New("unavailable"), before: func() { time.Sleep(5 * time.Millisecond) }}
+	s := &fakeSource{steps: []step{failure, failure, failure, failure, {result: values(0, "x", 1.0)}}}
+	r := &fakeRenderer{}
+	p := newTestPipeline(s, r, data.Field{ID: "x", Name: "x"})
+	p.Interval = time.Millisecond
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.frames) == 0 || !r.frames[len(r.frames)-1].last {
+		t.Fatalf("frames = %v, want a last frame", r.frames)
+	}
+	for _, f := range r.frames {
+		if f.points == 0 {
+			t.Errorf("frames = %v, want no frame before the first result", r.frames)
+			break
+		}
+	}
+
+	// no frame at all if the source never returned a result
+	s = &fakeSource{steps: []step{failure, failure}}
+	r = &fakeRenderer{}
+	p = newTestPipeline(s, r, data.Field{ID: "x", Name: "x"})
+	p.Interval = time.Millisecond
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.frames) != 0 {
+		t.Errorf("frames = %v, want none", r.frames)
+	}
+}
+
+func TestRunMaxFailures(t *testing.T) {
+	failure := step{err: errors.New("unavailable")}
+	s := &fakeSource{steps: []step{
+		{result: values(0, "x", 1.0)},
+		failure, failure,
+		{result: values(1, "x", 2.0)},
+		failure, failure, failure, failure,
+	}}
+	r := &fakeRenderer{}
+	p := newTestPipeline(s, r, data.Field{ID: "x", Name: "x"})
+	p.MaxFailures = 3
+	var statuses []string
+	p.Status = func(msg string) { statuses = append(statuses, msg) }
+	if err := p.Run(context.Background()); err == nil {
+		t.Fatal("Run() = nil, want an error after 3 consecutive failures")
+	}
+	if got := s.Gets(); got != 7 {
+		t.Errorf("Get called %d times, want 7", got)
+	}
+	// the status is cleared once the source recovers
+	if len(statuses) < 3 || statuses[2] != "" {
+		t.Errorf("statuses = %q, want the failures cleared by the second result", statuses)
+	}
+	// the frame of the values fetched so far is rendered
+	if len(r.frames) != 1 || !r.frames[0].last {
+		t.Errorf("frames = %v, want a last frame", r.frames)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &fakeSource{steps: []step{
+		{result: values(0, "x", 1.0)},
+		{result: values(1, "x", 2.0), before: cancel},
+		{result: values(2, "x", 3.0)},
+	}}
+	r := &fakeRenderer{}
+	p := newTestPipeline(s, r, data.Field{ID: "x", Name: "x"})
+	if err := p.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Gets(); got != 2 {
+		t.Errorf("Get called %d times, want 2", got)
+	}
+	// the result received with the cancelation is dropped
+	want := []frame{{last: true, points: 1}}
+	if len(r.frames) != 1 || r.frames[0] != want[0] {
+		t.Errorf("frames = %v, want %v", r.frames, want)
+	}
+}
+
+func TestRunGaps(t *testing.T) {
+	s := &fakeSource{steps: []step{
+		{result: values(0, "x", 1.0, "y", 1.0)},
+		{result: values(1, "x", 2.0)},
+		{result: values(2, "x", 3.0, "y", 3.0)},
+	}}
+	r := &fakeRenderer{}
+	p := newTestPipeline(s, r, data.Field{ID: "x", Name: "x"}, data.Field{ID: "y", Name: "y"})
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got := p.DataSet.Get("y")
+	if len(got) != 3 || got[0].IsGap() || !got[1].IsGap() || got[2].IsGap() {
+		t.Fatalf("y = %v, want a gap in the middle", got)
+	}
+	if want := epoch.Add(time.Second); !got[1].Timestamp.Equal(want) {
+		t.Errorf("gap at %v, want %v", got[1].Timestamp, want)
+	}
+	for _, pt := range p.DataSet.Get("x") {
+		if pt.IsGap() {
+			t.Errorf("x = %v, want no gap", p.DataSet.Get("x"))
+			break
+		}
+	}
+}
+
+func TestRunMaxSeries(t *testing.T) {
+	f, err := data.ParseSelector("cpu.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.ID = "cpu"
+	s := &fakeSource{steps: []step{
+		{result: values(0, "cpu.a", 1.0, "cpu.b", 2.0)},
+		{result: values(1, "cpu.a", 1.0, "cpu.b", 2.0, "cpu.c", 3.0, "cpu.d", 4.0)},
+		{result: values(2, "cpu.c", 3.0, "cpu.e", 5.0)},
+	}}
+	r := &fakeRenderer{}
+	p := newTestPipeline(s, r, f)
+	p.MaxSeries = 3
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_, names := p.DataSet.Series(f)
+	if len(names) != 3 || names[0] != "cpu.a" || names[1] != "cpu.b" || names[2] != "cpu.c" {
+		t.Errorf("series = %v, want the first 3: [cpu.a cpu.b cpu.c]", names)
+	}
+	// series no longer selected get a gap
+	if a := p.DataSet.Get(f.SeriesID("cpu.a")); len(a) != 3 || !a[2].IsGap() {
+		t.Errorf("cpu.a = %v, want a gap last", a)
+	}
+}
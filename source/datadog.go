@@ -1,13 +1,19 @@
 package source
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/rs/jplot/data"
 	"gopkg.in/zorkian/go-datadog-api.v2"
 )
 
+// DatadogMinInterval is the minimum time between two queries to Datadog,
+// whose metrics have a coarse resolution.
+const DatadogMinInterval = 10 * time.Second
+
 type DatadogSource struct {
 	apiKey         string
 	applicationKey string
@@ -22,8 +28,9 @@ type DatadogSource struct {
 	lastQueryTime int64
 }
 
-func NewDatadog(apiKey, applicationKey, baseUrl string, specs []data.GraphSpec, interval time.Duration) *DatadogSource {
+func NewDatadog(apiKey, applicationKey, baseUrl string, specs []data.GraphSpec, interval, timeout time.Duration) *DatadogSource {
 	client := datadog.NewClient(apiKey, applicationKey)
+	client.HttpClient = &http.Client{Timeout: timeout}
 	if baseUrl != "" {
 		client.SetBaseUrl(baseUrl)
 	}
@@ -58,44 +65,72 @@ func (s *DatadogSource) fetch() {
 	maxUpdateTimestamp := s.lastQueryTime
 	dataPoints := make(map[string]data.Points, 0)
 	var err error
+	for name, counter := range s.metrics() {
+		query := s.formatQuery(name, counter)
+		var series []datadog.Series
+		series, err = s.client.QueryMetrics(s.lastQueryTime, time.Now().Unix(), query)
+		if err != nil {
+			break
+		}
+		dataPoints[name] = make(data.Points, 0)
+		if len(series) > 0 {
+			endTs := int64(series[0].GetEnd() / 1000)
+			if endTs > maxUpdateTimestamp {
+				maxUpdateTimestamp = endTs
+			}
+			// assume the last data point is hte latest
+			for _, ser := range series {
+				for _, dp := range ser.Points {
+					dataPoints[name] = append(dataPoints[name], data.Point{
+						Timestamp: time.Unix(0, int64(dp[0])),
+						Value:     dp[1],
+					})
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		s.send(Result{Err: err})
+		return
+	}
+	s.send(Result{DataPoints: dataPoints, Err: err})
+}
+
+// metrics returns the names of the metrics to query, including the ones used
+// by expressions, and whether they must be queried as counts.
+func (s *DatadogSource) metrics() map[string]bool {
+	metrics := map[string]bool{}
 	for _, spec := range s.specs {
 		for _, field := range spec.Fields {
-			query := s.formatQuery(field)
-			series, err := s.client.QueryMetrics(s.lastQueryTime, time.Now().Unix(), query)
-			dataPoints[field.ID] = make(data.Points, 0)
-			if err == nil && len(series) > 0 {
-				endTs := int64(series[0].GetEnd() / 1000)
-				if endTs > maxUpdateTimestamp {
-					maxUpdateTimestamp = endTs
-				}
-				// assume the last data point is hte latest
-				for _, ser := range series {
-					for _, dp := range ser.Points {
-						dataPoints[field.ID] = append(dataPoints[field.ID], data.Point{
-							Timestamp: time.Unix(0, int64(dp[0])),
-							Value:     dp[1],
-						})
+			if field.Expr != nil {
+				for _, name := range field.Expr.Fields() {
+					if _, found := metrics[name]; !found {
+						metrics[name] = false
 					}
 				}
-			} else if err != nil {
-				break
+				continue
 			}
+			metrics[field.Name] = metrics[field.Name] || field.Counter
 		}
 	}
+	return metrics
+}
 
-	if err != nil {
-		s.c <- Result{Err: err}
-		return
+// send hands res to Get, unless the source is closed.
+func (s *DatadogSource) send(res Result) {
+	select {
+	case s.c <- res:
+	case <-s.done:
 	}
-	s.c <- Result{DataPoints: dataPoints, Err: err}
 }
 
-func (s *DatadogSource) formatQuery(field data.Field) string {
+func (s *DatadogSource) formatQuery(name string, counter bool) string {
 	querySuffix := ""
-	if field.Counter {
+	if counter {
 		querySuffix = ".as_count()"
 	}
-	return fmt.Sprintf("avg:%s%s", field.Name, querySuffix)
+	return fmt.Sprintf("avg:%s%s", name, querySuffix)
 }
 
 func (s *DatadogSource) Close() error {
@@ -103,10 +138,14 @@ func (s *DatadogSource) Close() error {
 	return nil
 }
 
-func (s *DatadogSource) Get() (*Result, error) {
-	res := <-s.c
-	if res.Err != nil {
-		return nil, res.Err
+func (s *DatadogSource) Get(ctx context.Context) (*Result, error) {
+	select {
+	case res := <-s.c:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return &res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
-	return &res, nil
 }
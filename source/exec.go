@@ -0,0 +1,28 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Exec is a source running a command every interval and parsing its output as
+// expvar-like JSON.
+type Exec struct {
+	poller
+}
+
+// NewExec creates a source running command with sh every opts.Interval.
+func NewExec(command string, opts Options) Exec {
+	return Exec{newPoller(opts, func(ctx context.Context) (*Result, error) {
+		out, err := exec.CommandContext(ctx, "sh", "-c", command).Output()
+		if err != nil {
+			if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+				return nil, fmt.Errorf("%s: %v: %s", command, err, strings.TrimSpace(string(ee.Stderr)))
+			}
+			return nil, fmt.Errorf("%s: %v", command, err)
+		}
+		return JsonDataToResult(out)
+	})}
+}
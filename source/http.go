@@ -1,70 +1,112 @@
 package source
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
-	"time"
-	//"fmt"
 )
 
+// HTTP is a source polling an HTTP endpoint.
 type HTTP struct {
-	c    chan Result
-	done chan struct{}
+	poller
 }
 
-func NewHTTP(url string, interval time.Duration) HTTP {
-	h := HTTP{
-		c:    make(chan Result),
-		done: make(chan struct{}),
-	}
-	go h.run(url, interval)
-	return h
+// NewHTTP creates a source fetching expvar-like JSON from url every
+// opts.Interval.
+func NewHTTP(url string, opts Options) HTTP {
+	return newHTTP(url, opts, JsonDataToResult)
 }
 
-func (h HTTP) run(url string, interval time.Duration) {
-	t := time.NewTicker(interval)
-	defer t.Stop()
-	h.fetch(url)
-	for {
-		select {
-		case <-t.C:
-			h.fetch(url)
-		case <-h.done:
-			return
+func newHTTP(url string, opts Options, parse func([]byte) (*Result, error)) HTTP {
+	client := http.DefaultClient
+	if opts.TLSConfig != nil {
+		// Keep the timeouts, connection limits and HTTP/2 support of the
+		// default transport.
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = opts.TLSConfig
+		client = &http.Client{Transport: t}
+	}
+	return HTTP{newPoller(opts, func(ctx context.Context) (*Result, error) {
+		req, err := newRequest(url, opts)
+		if err != nil {
+			return nil, err
 		}
+		return getHTTP(client, req.WithContext(ctx), parse)
+	})}
+}
+
+// newRequest creates the request fetching url with the headers and
+// credentials of opts.
+func newRequest(url string, opts Options) (*http.Request, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
 	}
+	for name, values := range opts.Header {
+		req.Header[name] = values
+	}
+	if host := opts.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	if opts.Username != "" {
+		req.SetBasicAuth(opts.Username, opts.Password)
+	}
+	return req, nil
 }
 
-func (h HTTP) fetch(url string) {
-	resp, err := http.Get(url)
+func getHTTP(client *http.Client, req *http.Request, parse func([]byte) (*Result, error)) (*Result, error) {
+	resp, err := client.Do(req)
 	if err != nil {
-		h.c <- Result{Err: err}
-		return
+		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", req.URL, resp.Status)
+	}
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		h.c <- Result{Err: err}
-		return
+		return nil, err
 	}
-	result, err := JsonDataToResult(b)
-	if err != nil || result.Err != nil {
-		h.c <- Result{Err: err}
-		return
-	} else {
-		h.c <- *result
+	result, err := parse(b)
+	if err != nil {
+		return nil, err
 	}
-}
-
-func (h HTTP) Get() (*Result, error) {
-	res := <-h.c
-	if res.Err != nil {
-		return nil, res.Err
+	if result.Err != nil {
+		return nil, result.Err
 	}
-	return &res, nil
+	return result, nil
 }
 
-func (h HTTP) Close() error {
-	close(h.done)
-	return nil
+// NewTLSConfig creates the TLS configuration of HTTP sources. If caFile is
+// set, servers are verified with the PEM certificates it contains instead of
+// the system ones. If certFile and keyFile are set, the PEM certificate and
+// key they contain are presented to servers requesting a client
+// certificate. insecure disables the verification of servers.
+func NewTLSConfig(caFile, certFile, keyFile string, insecure bool) (*tls.Config, error) {
+	c := &tls.Config{InsecureSkipVerify: insecure}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no PEM certificate found", caFile)
+		}
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("a client certificate needs both a certificate and a key")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
 }
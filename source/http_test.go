@@ -0,0 +1,283 @@
+package source
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate and its key, as PEM files and as loaded by tls.
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	tls      tls.Certificate
+	certFile string
+	keyFile  string
+}
+
+// newTestCert creates a certificate from template written to dir as
+// name.pem and name.key. It is signed by parent, or self-signed if nil.
+func newTestCert(t *testing.T, dir, name string, template *x509.Certificate, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	c := &testCert{
+		cert:     cert,
+		key:      key,
+		certFile: filepath.Join(dir, name+".pem"),
+		keyFile:  filepath.Join(dir, name+".key"),
+	}
+	if c.tls, err = tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(c.certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(c.keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// testPKI is a CA with a server certificate for 127.0.0.1 and a client
+// certificate it signed.
+type testPKI struct {
+	ca, server, client *testCert
+}
+
+func newTestPKI(t *testing.T, dir string) testPKI {
+	ca := newTestCert(t, dir, "ca", &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	return testPKI{
+		ca: ca,
+		server: newTestCert(t, dir, "server", &x509.Certificate{
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}, ca),
+		client: newTestCert(t, dir, "client", &x509.Certificate{
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}, ca),
+	}
+}
+
+// expvarHandler serves a single x value.
+var expvarHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(`{"x": 1}`))
+})
+
+// getOnce fetches a single result of an HTTP source.
+func getOnce(url string, opts Options) (*Result, error) {
+	opts.Interval = time.Hour
+	opts.Timeout = 5 * time.Second
+	s := NewHTTP(url, opts)
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return s.Get(ctx)
+}
+
+func TestHTTPHeaderAndBasicAuth(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-Token") != "abc" || r.Host != "metrics.local" {
+			http.Error(w, "missing header", http.StatusBadRequest)
+			return
+		}
+		expvarHandler(w, r)
+	}))
+	defer ts.Close()
+	tlsConfig, err := NewTLSConfig("", "", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{
+		Header:    http.Header{"X-Token": {"abc"}, "Host": {"metrics.local"}},
+		Username:  "user",
+		Password:  "secret",
+		TLSConfig: tlsConfig,
+	}
+	res, err := getOnce(ts.URL, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.DataPoints["x"]) != 1 {
+		t.Errorf("DataPoints = %v, want x", res.DataPoints)
+	}
+
+	opts.Password = "wrong"
+	if _, err := getOnce(ts.URL, opts); err == nil {
+		t.Error("Get() with a wrong password succeeded, want an error")
+	}
+	opts.Password = "secret"
+	opts.Header = nil
+	if _, err := getOnce(ts.URL, opts); err == nil {
+		t.Error("Get() without headers succeeded, want an error")
+	}
+}
+
+func TestHTTPTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jplot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pki := newTestPKI(t, dir)
+	other := newTestCert(t, dir, "other", &x509.Certificate{
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, nil)
+	// failed handshakes are expected
+	quiet := log.New(ioutil.Discard, "", 0)
+
+	// server with a certificate signed by the test CA
+	signed := httptest.NewUnstartedServer(expvarHandler)
+	signed.TLS = &tls.Config{Certificates: []tls.Certificate{pki.server.tls}}
+	signed.Config.ErrorLog = quiet
+	signed.StartTLS()
+	defer signed.Close()
+
+	// server with the default httptest certificate
+	unknown := httptest.NewUnstartedServer(expvarHandler)
+	unknown.Config.ErrorLog = quiet
+	unknown.StartTLS()
+	defer unknown.Close()
+
+	// server requiring a client certificate signed by the test CA
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(pki.ca.cert)
+	mutual := httptest.NewUnstartedServer(expvarHandler)
+	mutual.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pki.server.tls},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	mutual.Config.ErrorLog = quiet
+	mutual.StartTLS()
+	defer mutual.Close()
+
+	tests := []struct {
+		name              string
+		url               string
+		caFile            string
+		certFile, keyFile string
+		insecure          bool
+		wantErr           bool
+	}{
+		{name: "ca cert", url: signed.URL, caFile: pki.ca.certFile},
+		{name: "system roots", url: signed.URL, wantErr: true},
+		{name: "ca cert of another server", url: unknown.URL, caFile: pki.ca.certFile, wantErr: true},
+		{name: "insecure", url: unknown.URL, insecure: true},
+		{name: "client cert", url: mutual.URL, caFile: pki.ca.certFile, certFile: pki.client.certFile, keyFile: pki.client.keyFile},
+		{name: "no client cert", url: mutual.URL, caFile: pki.ca.certFile, wantErr: true},
+		{name: "client cert not signed by the ca", url: mutual.URL, caFile: pki.ca.certFile, certFile: other.certFile, keyFile: other.keyFile, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := NewTLSConfig(tt.caFile, tt.certFile, tt.keyFile, tt.insecure)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = getOnce(tt.url, Options{TLSConfig: tlsConfig})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Get() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHTTPTLSHTTP2(t *testing.T) {
+	var proto string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto = r.Proto
+		expvarHandler(w, r)
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+	tlsConfig, err := NewTLSConfig("", "", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getOnce(ts.URL, Options{TLSConfig: tlsConfig}); err != nil {
+		t.Fatal(err)
+	}
+	if proto != "HTTP/2.0" {
+		t.Errorf("protocol = %s, want HTTP/2.0", proto)
+	}
+}
+
+func TestNewTLSConfigErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jplot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pki := newTestPKI(t, dir)
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := ioutil.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name                      string
+		caFile, certFile, keyFile string
+	}{
+		{name: "missing ca file", caFile: filepath.Join(dir, "missing.pem")},
+		{name: "ca file without certificate", caFile: notPEM},
+		{name: "cert without key", certFile: pki.client.certFile},
+		{name: "key without cert", keyFile: pki.client.keyFile},
+		{name: "mismatched key", certFile: pki.client.certFile, keyFile: pki.server.keyFile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTLSConfig(tt.caFile, tt.certFile, tt.keyFile, false); err == nil {
+				t.Error("NewTLSConfig() = nil error, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+)
+
+// JSONLines is a source reading one JSON object per line, from stdin, a file
+// or UDP datagrams. Nested objects are flattened the same way as with
+// JsonDataToResult.
+type JSONLines struct {
+	lines chan line
+	// closer, if set, releases the input on Close.
+	closer io.Closer
+}
+
+// line is a line read by JSONLines, or the error which stopped the reading.
+type line struct {
+	b   []byte
+	err error
+}
+
+// NewStdin creates a source reading JSON lines from stdin.
+func NewStdin() JSONLines {
+	s := JSONLines{lines: make(chan line)}
+	go s.read(os.Stdin)
+	return s
+}
+
+// NewFile creates a source reading JSON lines from the file at path. The
+// source is exhausted at the end of the file.
+func NewFile(path string) (JSONLines, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return JSONLines{}, err
+	}
+	s := JSONLines{lines: make(chan line), closer: f}
+	go s.read(f)
+	return s, nil
+}
+
+// NewUDP creates a source listening on addr for UDP datagrams holding JSON
+// lines.
+func NewUDP(addr string) (JSONLines, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return JSONLines{}, err
+	}
+	s := JSONLines{lines: make(chan line), closer: conn}
+	go s.receive(conn)
+	return s, nil
+}
+
+// read sends the lines of r to Get. As reading cannot be interrupted, it is
+// done in the background so Get can return when its context is canceled.
+func (s JSONLines) read(r io.Reader) {
+	scan := bufio.NewScanner(r)
+	scan.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scan.Scan() {
+		b := make([]byte, len(scan.Bytes()))
+		copy(b, scan.Bytes())
+		s.lines <- line{b: b}
+	}
+	if err := scan.Err(); err != nil {
+		s.lines <- line{err: FatalError{fmt.Errorf("cannot read input: %v", err)}}
+	}
+	close(s.lines)
+}
+
+// receive sends the lines of the datagrams received on conn to Get, until
+// conn is closed.
+func (s JSONLines) receive(conn net.PacketConn) {
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			// closed
+			return
+		}
+		for _, b := range bytes.Split(buf[:n], []byte("\n")) {
+			if len(bytes.TrimSpace(b)) == 0 {
+				continue
+			}
+			l := make([]byte, len(b))
+			copy(l, b)
+			s.lines <- line{b: l}
+		}
+	}
+}
+
+// Get reads the next JSON line. A nil result with a nil error is returned
+// once the input is exhausted, and a FatalError if it cannot be read.
+func (s JSONLines) Get(ctx context.Context) (*Result, error) {
+	select {
+	case l, ok := <-s.lines:
+		if !ok {
+			return nil, nil
+		}
+		if l.err != nil {
+			return nil, l.err
+		}
+		return JsonDataToResult(l.b)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s JSONLines) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
@@ -0,0 +1,46 @@
+package source
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/jplot/data"
+)
+
+// Named is a source qualifying the names of the values of another source with
+// a source name, like a:mem.heap, so the values of several sources exposing
+// the same fields can be told apart once merged.
+type Named struct {
+	Getter
+	name string
+}
+
+// NewNamed qualifies the values of g with name.
+func NewNamed(name string, g Getter) Named {
+	return Named{Getter: g, name: name}
+}
+
+func (n Named) Get(ctx context.Context) (*Result, error) {
+	res, err := n.Getter.Get(ctx)
+	if err != nil {
+		if fe, ok := err.(FatalError); ok {
+			return nil, FatalError{fmt.Errorf("%s: %v", n.name, fe.Err)}
+		}
+		return nil, fmt.Errorf("%s: %v", n.name, err)
+	}
+	if res == nil {
+		return nil, nil
+	}
+	qualified := &Result{
+		DataPoints: make(map[string]data.Points, len(res.DataPoints)),
+		Counters:   make(map[string]bool, len(res.Counters)),
+		Failures:   res.Failures,
+	}
+	for name, points := range res.DataPoints {
+		qualified.DataPoints[n.name+":"+name] = points
+	}
+	for name, counter := range res.Counters {
+		qualified.Counters[n.name+":"+name] = counter
+	}
+	return qualified, nil
+}
@@ -0,0 +1,103 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MaxBackoff is the maximum delay between two attempts to fetch a failing
+// polled source, like HTTP.
+var MaxBackoff = time.Minute
+
+// poller calls fetch every interval and hands the results to Get. Fetches
+// taking longer than timeout, if not 0, are canceled and fail. After a
+// failure, the delay before the next attempt doubles up to MaxBackoff, and
+// is reset to interval on success.
+type poller struct {
+	c chan Result
+	// ctx is canceled on Close to stop the pending fetch.
+	ctx    context.Context
+	cancel context.CancelFunc
+	fetch  func(ctx context.Context) (*Result, error)
+}
+
+func newPoller(opts Options, fetch func(ctx context.Context) (*Result, error)) poller {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := poller{
+		c:      make(chan Result),
+		ctx:    ctx,
+		cancel: cancel,
+		fetch:  fetch,
+	}
+	go p.run(opts.Interval, opts.Timeout)
+	return p
+}
+
+func (p poller) run(interval, timeout time.Duration) {
+	delay := interval
+	next := time.Now()
+	for {
+		result, err := p.fetchTimeout(timeout)
+		if err != nil {
+			p.send(Result{Err: err})
+			if delay *= 2; delay > MaxBackoff {
+				delay = MaxBackoff
+			}
+		} else {
+			p.send(*result)
+			delay = interval
+		}
+		next = next.Add(delay)
+		if now := time.Now(); next.Before(now) {
+			// fetching took longer than delay
+			next = now
+		}
+		t := time.NewTimer(next.Sub(time.Now()))
+		select {
+		case <-t.C:
+		case <-p.ctx.Done():
+			t.Stop()
+			return
+		}
+	}
+}
+
+// fetchTimeout calls fetch, canceling it after timeout if not 0.
+func (p poller) fetchTimeout(timeout time.Duration) (*Result, error) {
+	if timeout <= 0 {
+		return p.fetch(p.ctx)
+	}
+	ctx, cancel := context.WithTimeout(p.ctx, timeout)
+	defer cancel()
+	result, err := p.fetch(ctx)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%v (timeout after %v)", err, timeout)
+	}
+	return result, err
+}
+
+// send hands res to Get, unless the source is closed.
+func (p poller) send(res Result) {
+	select {
+	case p.c <- res:
+	case <-p.ctx.Done():
+	}
+}
+
+func (p poller) Get(ctx context.Context) (*Result, error) {
+	select {
+	case res := <-p.c:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return &res, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p poller) Close() error {
+	p.cancel()
+	return nil
+}
@@ -0,0 +1,117 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/jplot/data"
+)
+
+// NewPrometheus creates an HTTP source reading the Prometheus text exposition
+// format from url every opts.Interval.
+func NewPrometheus(url string, opts Options) HTTP {
+	return newHTTP(url, opts, PrometheusDataToResult)
+}
+
+// PrometheusDataToResult parses the Prometheus text exposition format. Each
+// series is named after its metric name followed by its labels sorted by
+// name, like http_requests_total{code="200",method="get"}. Counters, as well
+// as histogram and summary sums and counts, are listed in Result.Counters.
+func PrometheusDataToResult(raw []byte) (*Result, error) {
+	now := time.Now()
+	types := map[string]string{}
+	dataPoints := make(map[string]data.Points, 0)
+	counters := map[string]bool{}
+	for n, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if line[0] == '#' {
+			// # HELP name doc or # TYPE name type
+			f := strings.Fields(line)
+			if len(f) >= 4 && f[1] == "TYPE" {
+				types[f[2]] = f[3]
+			}
+			continue
+		}
+		name, labels, p, err := parsePrometheusSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+			continue
+		}
+		if p.Timestamp.IsZero() {
+			p.Timestamp = now
+		}
+		key := data.SeriesName(name, labels)
+		dataPoints[key] = append(dataPoints[key], p)
+		if isPrometheusCounter(types, name) {
+			counters[key] = true
+		}
+	}
+	return &Result{DataPoints: dataPoints, Counters: counters}, nil
+}
+
+// parsePrometheusSample parses a sample line: name{labels} value [timestamp].
+func parsePrometheusSample(line string) (string, []data.Label, data.Point, error) {
+	var p data.Point
+	i := strings.IndexAny(line, "{ \t")
+	if i <= 0 {
+		return "", nil, p, errors.New("missing value")
+	}
+	name := line[:i]
+	rest := line[i:]
+	var labels []data.Label
+	if rest[0] == '{' {
+		var err error
+		labels, rest, err = data.ParseLabels(rest)
+		if err != nil {
+			return "", nil, p, err
+		}
+	}
+	f := strings.Fields(rest)
+	if len(f) == 0 || len(f) > 2 {
+		return "", nil, p, fmt.Errorf("invalid sample: %s", rest)
+	}
+	v, err := strconv.ParseFloat(f[0], 64)
+	if err != nil {
+		return "", nil, p, fmt.Errorf("invalid value: %s", f[0])
+	}
+	p.Value = v
+	if len(f) == 2 {
+		ms, err := strconv.ParseInt(f[1], 10, 64)
+		if err != nil {
+			return "", nil, p, fmt.Errorf("invalid timestamp: %s", f[1])
+		}
+		p.Timestamp = time.Unix(0, ms*int64(time.Millisecond))
+	}
+	return name, labels, p, nil
+}
+
+// isPrometheusCounter tells if the sample name is monotonic given the TYPE
+// declarations seen so far.
+func isPrometheusCounter(types map[string]string, name string) bool {
+	if types[name] == "counter" {
+		return true
+	}
+	for _, suffix := range []string{"_total", "_bucket", "_sum", "_count"} {
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		switch types[strings.TrimSuffix(name, suffix)] {
+		case "counter":
+			return suffix == "_total"
+		case "histogram":
+			return suffix != "_total"
+		case "summary":
+			return suffix == "_sum" || suffix == "_count"
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package source
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestPrometheusDataToResult(t *testing.T) {
+	raw, err := ioutil.ReadFile("testdata/metrics.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	res, err := PrometheusDataToResult(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		value   float64
+		ms      int64 // 0 for the time of the scrape
+		counter bool
+	}{
+		{`http_requests_total{code="200",method="post"}`, 1027, 1395066363000, true},
+		{`http_requests_total{code="400",method="post"}`, 3, 1395066363000, true},
+		{"jobs_total", 12, 0, true},
+		{`msdos_file_access_time_seconds{error="Cannot find file:\n\"FILE.TXT\"",path="C:\\DIR\\FILE.TXT"}`, 1.458255915e9, 0, false},
+		{"metric_without_timestamp_and_labels", 12.47, 0, false},
+		{`temperature{room="a,b}:c"}`, -45, 0, false},
+		{`http_request_duration_seconds_bucket{le="0.05"}`, 24054, 0, true},
+		{`http_request_duration_seconds_bucket{le="0.5"}`, 129389, 0, true},
+		{`http_request_duration_seconds_bucket{le="+Inf"}`, 144320, 0, true},
+		{"http_request_duration_seconds_sum", 53423, 0, true},
+		{"http_request_duration_seconds_count", 144320, 0, true},
+		{`rpc_duration_seconds{quantile="0.01"}`, 3102, 0, false},
+		{`rpc_duration_seconds{quantile="0.99"}`, 76656, 0, false},
+		{"rpc_duration_seconds_sum", 1.7560473e+07, 0, true},
+		{"rpc_duration_seconds_count", 2693, 0, true},
+	}
+	for _, tt := range tests {
+		points, found := res.DataPoints[tt.name]
+		if !found || len(points) != 1 {
+			t.Errorf("%s: points = %v, want one point", tt.name, points)
+			continue
+		}
+		p := points[0]
+		if p.Value != tt.value {
+			t.Errorf("%s: value = %v, want %v", tt.name, p.Value, tt.value)
+		}
+		if tt.ms == 0 {
+			if p.Timestamp.Before(before) || p.Timestamp.After(time.Now()) {
+				t.Errorf("%s: timestamp = %v, want the time of the scrape", tt.name, p.Timestamp)
+			}
+		} else if want := time.Unix(0, tt.ms*int64(time.Millisecond)); !p.Timestamp.Equal(want) {
+			t.Errorf("%s: timestamp = %v, want %v", tt.name, p.Timestamp, want)
+		}
+		if res.Counters[tt.name] != tt.counter {
+			t.Errorf("%s: counter = %v, want %v", tt.name, res.Counters[tt.name], tt.counter)
+		}
+	}
+	// infinite and NaN values are skipped
+	if len(res.DataPoints) != len(tests) {
+		var names []string
+		for name := range res.DataPoints {
+			names = append(names, name)
+		}
+		t.Errorf("series = %q, want %d series", names, len(tests))
+	}
+}
+
+func TestPrometheusDataToResultErrors(t *testing.T) {
+	for _, in := range []string{
+		"x",
+		"{a=\"1\"} 1",
+		"x{a=\"1\" 1",
+		"x{a=1} 1",
+		"x abc",
+		"x 1 abc",
+		"x 1 2 3",
+		"ok 1\nx{} ",
+	} {
+		if _, err := PrometheusDataToResult([]byte(in)); err == nil {
+			t.Errorf("PrometheusDataToResult(%q) = nil error, want an error", in)
+		}
+	}
+}
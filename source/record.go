@@ -0,0 +1,221 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rs/jplot/data"
+)
+
+// record is the representation of a Result in a recording. Recordings are
+// made of one JSON record per line.
+type record struct {
+	// Time is when the result was fetched.
+	Time     time.Time                `json:"t"`
+	Points   map[string][]recordPoint `json:"p"`
+	Counters []string                 `json:"c,omitempty"`
+	// Sources are the names qualifying the fields of named sources, saved
+	// with the first record of a session.
+	Sources []string `json:"s,omitempty"`
+}
+
+// recordPoint is a data.Point encoded as a [timestamp, value] array, the
+// timestamp being in nanoseconds since the epoch.
+type recordPoint data.Point
+
+func (p recordPoint) MarshalJSON() ([]byte, error) {
+	v := strconv.FormatFloat(p.Value, 'g', -1, 64)
+	return []byte(fmt.Sprintf("[%d,%s]", p.Timestamp.UnixNano(), v)), nil
+}
+
+func (p *recordPoint) UnmarshalJSON(b []byte) error {
+	var a [2]json.Number
+	if err := json.Unmarshal(b, &a); err != nil {
+		return err
+	}
+	ts, err := a[0].Int64()
+	if err != nil {
+		return err
+	}
+	v, err := a[1].Float64()
+	if err != nil {
+		return err
+	}
+	p.Timestamp = time.Unix(0, ts)
+	p.Value = v
+	return nil
+}
+
+// Recorder is a Getter appending every result of another Getter to a file,
+// so the session can be replayed later with Replay.
+type Recorder struct {
+	// Sources, if set, are the names of the sources qualifying the recorded
+	// fields, like a for a:mem.heap, so they can be told apart from field
+	// options when replaying. See RecordedSources.
+	Sources []string
+
+	g       Getter
+	f       *os.File
+	enc     *json.Encoder
+	started bool
+}
+
+// NewRecorder records the results of g to the file at path. Results are
+// appended if the file already exists.
+func NewRecorder(g Getter, path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{g: g, f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (r *Recorder) Get(ctx context.Context) (*Result, error) {
+	res, err := r.g.Get(ctx)
+	if err != nil || res == nil {
+		return res, err
+	}
+	rec := record{
+		Time:   time.Now(),
+		Points: make(map[string][]recordPoint, len(res.DataPoints)),
+	}
+	for name, points := range res.DataPoints {
+		rp := make([]recordPoint, 0, len(points))
+		for _, p := range points {
+			if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+				// not representable in JSON
+				continue
+			}
+			rp = append(rp, recordPoint(p))
+		}
+		rec.Points[name] = rp
+	}
+	for name, counter := range res.Counters {
+		if counter {
+			rec.Counters = append(rec.Counters, name)
+		}
+	}
+	if !r.started {
+		rec.Sources = r.Sources
+		r.started = true
+	}
+	// Each record is written with a single write so an interrupted session
+	// leaves complete lines behind.
+	if err := r.enc.Encode(rec); err != nil {
+		return nil, fmt.Errorf("cannot record: %v", err)
+	}
+	return res, nil
+}
+
+func (r *Recorder) Close() error {
+	err := r.g.Close()
+	if ferr := r.f.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// RecordedSources returns the sorted names of the sources saved in the
+// recording at path by Recorder.
+func RecordedSources(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scan := newRecordScanner(f)
+	seen := map[string]bool{}
+	var names []string
+	for scan.Scan() {
+		var rec struct {
+			Sources []string `json:"s"`
+		}
+		if err := json.Unmarshal(scan.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("invalid record: %v", err)
+		}
+		for _, name := range rec.Sources {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, scan.Err()
+}
+
+// newRecordScanner returns a scanner reading the records of r, which can be
+// long lines.
+func newRecordScanner(r io.Reader) *bufio.Scanner {
+	scan := bufio.NewScanner(r)
+	scan.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	return scan
+}
+
+// Replay is a Getter reading back the results recorded by a Recorder.
+type Replay struct {
+	f     *os.File
+	scan  *bufio.Scanner
+	speed float64
+	last  time.Time
+}
+
+// NewReplay replays the recording at path. Results are returned with the
+// delays they were recorded with divided by speed, or as fast as possible if
+// speed is 0.
+func NewReplay(path string, speed float64) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Replay{f: f, scan: newRecordScanner(f), speed: speed}, nil
+}
+
+// Get returns the next recorded result. A nil result with a nil error is
+// returned once the recording is exhausted.
+func (r *Replay) Get(ctx context.Context) (*Result, error) {
+	if !r.scan.Scan() {
+		return nil, r.scan.Err()
+	}
+	var rec record
+	if err := json.Unmarshal(r.scan.Bytes(), &rec); err != nil {
+		return nil, fmt.Errorf("invalid record: %v", err)
+	}
+	if r.speed > 0 && !r.last.IsZero() {
+		t := time.NewTimer(time.Duration(float64(rec.Time.Sub(r.last)) / r.speed))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		}
+	}
+	r.last = rec.Time
+	res := &Result{
+		DataPoints: make(map[string]data.Points, len(rec.Points)),
+		Counters:   make(map[string]bool, len(rec.Counters)),
+	}
+	for name, rp := range rec.Points {
+		points := make(data.Points, len(rp))
+		for i, p := range rp {
+			points[i] = data.Point(p)
+		}
+		res.DataPoints[name] = points
+	}
+	for _, name := range rec.Counters {
+		res.Counters[name] = true
+	}
+	return res, nil
+}
+
+func (r *Replay) Close() error {
+	return r.f.Close()
+}
@@ -1,6 +1,7 @@
 package source
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/rs/jplot/data"
@@ -11,12 +12,29 @@ import (
 
 type Result struct {
 	DataPoints map[string]data.Points
-	Err        error
+	// Counters lists the series the source knows to be monotonic counters.
+	Counters map[string]bool
+	// Failures holds the errors of the sources of a merged result which
+	// failed. Their values are missing from the result.
+	Failures []error
+	Err      error
 }
 
+// FatalError is returned by sources which cannot return any more results,
+// like after a read error, as opposed to failures worth retrying.
+type FatalError struct {
+	Err error
+}
+
+func (e FatalError) Error() string {
+	return e.Err.Error()
+}
+
+// Getter is a source of values. Get blocks until the next result is
+// available or ctx is canceled, in which case ctx.Err() is returned.
 type Getter interface {
 	io.Closer
-	Get() (*Result, error)
+	Get(ctx context.Context) (*Result, error)
 }
 
 func jsonSubMapAppendData(upperKey string, now time.Time, dataPoints *map[string]data.Points, m map[string]interface{}) {
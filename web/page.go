@@ -0,0 +1,189 @@
+package web
+
+// page is the HTML page drawing the graphs in the browser. It loads the
+// retained points from /api/data, then merges the points streamed by
+// /api/stream by timestamp.
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>jplot</title>
+<style>
+html, body { margin: 0; height: 100%; background: #1e1e1e; color: #b4b4b4; font: 11px sans-serif; }
+canvas { display: block; width: 100%; }
+</style>
+</head>
+<body>
+<script>
+var colors = ["#ff6347", "#00bfff", "#ffd700", "#7cfc00", "#ff69b4", "#9370db", "#ffa500", "#40e0d0"];
+var dataset = {size: 0, window: 0, graphs: []};
+var canvases = [];
+
+function si(v) {
+	var prefixes = ["p", "n", "µ", "m", "", "k", "M", "G", "T", "P", "E"];
+	var i = 4, a = Math.abs(v);
+	while (a >= 1000 && i < prefixes.length - 1) { a /= 1000; v /= 1000; i++; }
+	while (a > 0 && a < 1 && i > 0) { a *= 1000; v *= 1000; i--; }
+	return (Math.floor(v * 100) / 100) + " " + prefixes[i];
+}
+
+function pad(n) {
+	return n < 10 ? "0" + n : "" + n;
+}
+
+function trim(points, end) {
+	if (dataset.window > 0) {
+		while (points.length > 0 && points[0][0] < end - dataset.window) points.shift();
+	} else if (dataset.size > 0 && points.length > dataset.size) {
+		points.splice(0, points.length - dataset.size);
+	}
+}
+
+function layout() {
+	var h = Math.floor(window.innerHeight / Math.max(dataset.graphs.length, 1));
+	canvases.forEach(function(c) {
+		c.style.height = h + "px";
+		c.width = c.clientWidth * (window.devicePixelRatio || 1);
+		c.height = h * (window.devicePixelRatio || 1);
+	});
+	draw();
+}
+
+function draw() {
+	dataset.graphs.forEach(function(g, i) { drawGraph(canvases[i], g.series || []); });
+}
+
+function drawGraph(c, series) {
+	var ctx = c.getContext("2d"), ratio = window.devicePixelRatio || 1;
+	var w = c.width / ratio, h = c.height / ratio;
+	ctx.setTransform(ratio, 0, 0, ratio, 0, 0);
+	ctx.clearRect(0, 0, w, h);
+	var minX = Infinity, maxX = -Infinity, minY = Infinity, maxY = -Infinity;
+	series.forEach(function(s) {
+		s.points.concat(s.min || [], s.max || []).forEach(function(p) {
+			minX = Math.min(minX, p[0]); maxX = Math.max(maxX, p[0]);
+			if (!s.marker && p[1] !== null) { minY = Math.min(minY, p[1]); maxY = Math.max(maxY, p[1]); }
+		});
+	});
+	if (minX > maxX || minY > maxY) return;
+	if (dataset.window > 0) minX = maxX - dataset.window;
+	if (minX == maxX) minX -= 1000;
+	if (minY == maxY) { minY -= 1; maxY += 1; }
+	var left = 5, right = w - 60, top = 5, bottom = h - 20;
+	var x = function(v) { return left + (v - minX) / (maxX - minX) * (right - left); };
+	var y = function(v) { return bottom - (v - minY) / (maxY - minY) * (bottom - top); };
+
+	// axes
+	ctx.strokeStyle = "#b4b4b4";
+	ctx.fillStyle = "#b4b4b4";
+	ctx.lineWidth = 1;
+	ctx.beginPath();
+	ctx.moveTo(left, bottom); ctx.lineTo(right, bottom); ctx.lineTo(right, top);
+	ctx.stroke();
+	ctx.textBaseline = "middle";
+	[minY, (minY + maxY) / 2, maxY].forEach(function(v) { ctx.fillText(si(v), right + 4, y(v)); });
+	ctx.textBaseline = "top";
+	[minX, (minX + maxX) / 2, maxX].forEach(function(v, i) {
+		var d = new Date(v), label = pad(d.getHours()) + ":" + pad(d.getMinutes()) + ":" + pad(d.getSeconds());
+		ctx.textAlign = ["left", "center", "right"][i];
+		ctx.fillText(label, x(v), bottom + 4);
+	});
+	ctx.textAlign = "left";
+
+	// markers
+	ctx.strokeStyle = "rgba(180, 180, 180, 0.4)";
+	ctx.lineWidth = 2;
+	ctx.setLineDash([2, 2]);
+	series.forEach(function(s) {
+		if (!s.marker) return;
+		s.points.forEach(function(p) {
+			if (p[1] <= 0) return;
+			ctx.beginPath(); ctx.moveTo(x(p[0]), top); ctx.lineTo(x(p[0]), bottom); ctx.stroke();
+		});
+	});
+	ctx.setLineDash([]);
+
+	// lines and legend, gaps being null values
+	var legend = 0;
+	series.forEach(function(s) {
+		var values = s.points.filter(function(p) { return p[1] !== null; });
+		if (s.marker || values.length == 0) return;
+		var color = colors[legend % colors.length];
+		if (s.min && s.max && s.min.length > 0 && s.min.length == s.max.length) {
+			// envelope of the min and max of downsampled buckets
+			ctx.fillStyle = color;
+			ctx.globalAlpha = 0.2;
+			ctx.beginPath();
+			s.max.forEach(function(p) { ctx.lineTo(x(p[0]), y(p[1])); });
+			s.min.slice().reverse().forEach(function(p) { ctx.lineTo(x(p[0]), y(p[1])); });
+			ctx.fill();
+			ctx.globalAlpha = 1;
+		}
+		ctx.strokeStyle = color;
+		ctx.beginPath();
+		var gap = true;
+		s.points.forEach(function(p) {
+			if (p[1] === null) { gap = true; return; }
+			if (gap) ctx.moveTo(x(p[0]), y(p[1])); else ctx.lineTo(x(p[0]), y(p[1]));
+			gap = false;
+		});
+		ctx.stroke();
+		var last = values[values.length - 1][1];
+		ctx.fillStyle = color;
+		ctx.fillRect(left + 5, top + 5 + legend * 14, 8, 8);
+		ctx.fillStyle = "#ffffff";
+		ctx.textBaseline = "top";
+		ctx.fillText(s.name + ": " + si(last), left + 18, top + 4 + legend * 14);
+		legend++;
+	});
+}
+
+// merge adds updates to points ordered by timestamp, replacing the points
+// with the same timestamp, like the open bucket of a tier.
+function merge(points, updates) {
+	updates.forEach(function(p) {
+		var i = points.length;
+		while (i > 0 && points[i - 1][0] > p[0]) i--;
+		if (i > 0 && points[i - 1][0] == p[0]) points[i - 1] = p;
+		else points.splice(i, 0, p);
+	});
+	return points;
+}
+
+function update(updates) {
+	var end = 0;
+	updates.forEach(function(u) {
+		var g = dataset.graphs[u.graph];
+		g.series = g.series || [];
+		var s = g.series.filter(function(s) { return s.id == u.id; })[0];
+		if (!s) {
+			s = {id: u.id, name: u.name, marker: u.marker, points: []};
+			g.series.push(s);
+		}
+		s.points = merge(s.points, u.points);
+		if (u.min) s.min = merge(s.min || [], u.min);
+		if (u.max) s.max = merge(s.max || [], u.max);
+		if (s.points.length > 0) end = Math.max(end, s.points[s.points.length - 1][0]);
+	});
+	dataset.graphs.forEach(function(g) {
+		(g.series || []).forEach(function(s) { [s.points, s.min || [], s.max || []].forEach(function(p) { trim(p, end); }); });
+	});
+	draw();
+}
+
+fetch("/api/data").then(function(r) { return r.json(); }).then(function(d) {
+	dataset = d;
+	dataset.graphs.forEach(function() {
+		var c = document.createElement("canvas");
+		document.body.appendChild(c);
+		canvases.push(c);
+	});
+	window.addEventListener("resize", layout);
+	layout();
+	var es = new EventSource("/api/stream");
+	es.onmessage = function(e) { update(JSON.parse(e.data)); };
+});
+</script>
+</body>
+</html>
+`
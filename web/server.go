@@ -0,0 +1,265 @@
+// Package web serves the graphs of a jplot session over HTTP, so they can be
+// watched from a browser.
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/rs/jplot/data"
+)
+
+// Server is an http.Handler exposing the specs and the points of a DataSet.
+//
+// It serves an HTML page drawing the graphs at /, the specs as JSON at
+// /api/specs, the retained points as JSON at /api/data, and new points as
+// they are received as Server-Sent Events at /api/stream.
+type Server struct {
+	specs []data.GraphSpec
+	ds    *data.DataSet
+	mux   *http.ServeMux
+	// Interval is the time between two checks for new points to stream.
+	Interval time.Duration
+}
+
+// NewServer creates a server for the graphs of specs, with points from ds.
+func NewServer(specs []data.GraphSpec, ds *data.DataSet) *Server {
+	s := &Server{
+		specs:    specs,
+		ds:       ds,
+		mux:      http.NewServeMux(),
+		Interval: time.Second,
+	}
+	s.mux.HandleFunc("/", s.servePage)
+	s.mux.HandleFunc("/api/specs", s.serveSpecs)
+	s.mux.HandleFunc("/api/data", s.serveData)
+	s.mux.HandleFunc("/api/stream", s.serveStream)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type field struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Counter bool   `json:"counter,omitempty"`
+	Rate    bool   `json:"rate,omitempty"`
+	Marker  bool   `json:"marker,omitempty"`
+	Dynamic bool   `json:"dynamic,omitempty"`
+}
+
+type graph struct {
+	Fields []field  `json:"fields,omitempty"`
+	Series []series `json:"series,omitempty"`
+}
+
+type series struct {
+	Graph  int     `json:"graph"`
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Marker bool    `json:"marker,omitempty"`
+	Points []point `json:"points"`
+	Min    []point `json:"min,omitempty"`
+	Max    []point `json:"max,omitempty"`
+}
+
+// point is a data.Point encoded as a [timestamp, value] array, the timestamp
+// being in milliseconds since the epoch as expected by JavaScript. Gaps have
+// a null value.
+type point data.Point
+
+func (p point) MarshalJSON() ([]byte, error) {
+	ms := p.Timestamp.UnixNano() / int64(time.Millisecond)
+	if data.Point(p).IsGap() {
+		return []byte(fmt.Sprintf("[%d,null]", ms)), nil
+	}
+	return []byte(fmt.Sprintf("[%d,%g]", ms, p.Value)), nil
+}
+
+type dataset struct {
+	// Size is the number of points kept per series, or Window their time
+	// span in milliseconds.
+	Size   int     `json:"size"`
+	Window int64   `json:"window"`
+	Graphs []graph `json:"graphs"`
+}
+
+func (s *Server) servePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, page)
+}
+
+func (s *Server) serveSpecs(w http.ResponseWriter, r *http.Request) {
+	graphs := make([]graph, 0, len(s.specs))
+	for _, gs := range s.specs {
+		var g graph
+		for _, f := range gs.Fields {
+			g.Fields = append(g.Fields, field{
+				ID:      f.ID,
+				Name:    f.Name,
+				Counter: f.Counter,
+				Rate:    f.Rate,
+				Marker:  f.Marker,
+				Dynamic: f.Dynamic(),
+			})
+		}
+		graphs = append(graphs, g)
+	}
+	writeJSON(w, graphs)
+}
+
+func (s *Server) serveData(w http.ResponseWriter, r *http.Request) {
+	d := dataset{
+		Size:   s.ds.Size,
+		Window: int64(s.ds.Window / time.Millisecond),
+		Graphs: make([]graph, len(s.specs)),
+	}
+	s.eachSeries(func(ser series) {
+		d.Graphs[ser.Graph].Series = append(d.Graphs[ser.Graph].Series, ser)
+	})
+	writeJSON(w, d)
+}
+
+// serveStream sends the points added or changed since the previous event as
+// a JSON array of series every Interval. Clients merge them by timestamp.
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	// Only points added or changed since the client connected are sent, the
+	// previous ones being fetched from /api/data. Points may change in place,
+	// like the open bucket of a tier or a value pushed again for the same
+	// time, or be inserted before the last one when received late.
+	sent := map[string]sentPoints{}
+	s.eachSeries(func(ser series) {
+		sent[ser.ID] = newSentPoints(ser)
+	})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+	t := time.NewTicker(s.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			return
+		}
+		var updates []series
+		s.eachSeries(func(ser series) {
+			prev := sent[ser.ID]
+			cur := newSentPoints(ser)
+			sent[ser.ID] = cur
+			ser.Points = changed(ser.Points, prev.points)
+			ser.Min = changed(ser.Min, prev.min)
+			ser.Max = changed(ser.Max, prev.max)
+			if len(ser.Points) == 0 && len(ser.Min) == 0 && len(ser.Max) == 0 {
+				return
+			}
+			updates = append(updates, ser)
+		})
+		if len(updates) == 0 {
+			continue
+		}
+		b, err := json.Marshal(updates)
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// eachSeries calls fn for every series of every graph with their retained
+// points, and the min and max of buckets for downsampled tiers, skipping
+// infinite values which are not representable in JSON.
+func (s *Server) eachSeries(fn func(series)) {
+	for i, gs := range s.specs {
+		for _, f := range gs.Fields {
+			ids, names := s.ds.Series(f)
+			for k, id := range ids {
+				vals, min, max := s.ds.GetRange(id, s.ds.Window, 0)
+				fn(series{
+					Graph:  i,
+					ID:     id,
+					Name:   names[k],
+					Marker: f.Marker,
+					Points: finite(vals),
+					Min:    finite(min),
+					Max:    finite(max),
+				})
+			}
+		}
+	}
+}
+
+// sentPoints are the values of the points of a series sent to a client, by
+// timestamp in nanoseconds.
+type sentPoints struct {
+	points, min, max map[int64]float64
+}
+
+func newSentPoints(ser series) sentPoints {
+	return sentPoints{
+		points: pointValues(ser.Points),
+		min:    pointValues(ser.Min),
+		max:    pointValues(ser.Max),
+	}
+}
+
+func pointValues(points []point) map[int64]float64 {
+	values := make(map[int64]float64, len(points))
+	for _, p := range points {
+		values[p.Timestamp.UnixNano()] = p.Value
+	}
+	return values
+}
+
+// changed returns the points which were not sent or have a different value
+// than when sent.
+func changed(points []point, sent map[int64]float64) []point {
+	var res []point
+	for _, p := range points {
+		v, found := sent[p.Timestamp.UnixNano()]
+		if found && (v == p.Value || math.IsNaN(v) && math.IsNaN(p.Value)) {
+			continue
+		}
+		res = append(res, p)
+	}
+	return res
+}
+
+// finite returns the points of vals which are not infinite.
+func finite(vals data.Points) []point {
+	if vals == nil {
+		return nil
+	}
+	points := make([]point, 0, len(vals))
+	for _, v := range vals {
+		if math.IsInf(v.Value, 0) {
+			continue
+		}
+		points = append(points, point(v))
+	}
+	return points
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,172 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/jplot/data"
+)
+
+var epoch = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func at(sec int) time.Time {
+	return epoch.Add(time.Duration(sec) * time.Second)
+}
+
+// ms returns the JSON timestamp of at(sec).
+func ms(sec int) float64 {
+	return float64(at(sec).UnixNano() / int64(time.Millisecond))
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *data.DataSet) {
+	ds := &data.DataSet{Size: 100}
+	ds.Push("x", at(0), 1, data.Gauge)
+	ds.PushGap("x", at(1))
+	ds.Push("x", at(2), 3, data.Gauge)
+	specs := []data.GraphSpec{
+		{Fields: []data.Field{{ID: "x", Name: "x", Counter: true}}},
+	}
+	srv := NewServer(specs, ds)
+	srv.Interval = 10 * time.Millisecond
+	return httptest.NewServer(srv), ds
+}
+
+func getJSON(t *testing.T, url string, v interface{}) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("%s Content-Type = %q, want application/json", url, ct)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// jsonSeries is a series as decoded by a client, gaps being nil values.
+type jsonSeries struct {
+	Graph  int           `json:"graph"`
+	ID     string        `json:"id"`
+	Name   string        `json:"name"`
+	Points [][2]*float64 `json:"points"`
+}
+
+// values returns the points of s as strings like "ms:value", "ms:null".
+func (s jsonSeries) values() []string {
+	var values []string
+	for _, p := range s.Points {
+		v := "null"
+		if p[1] != nil {
+			v = formatFloat(*p[1])
+		}
+		values = append(values, formatFloat(*p[0])+":"+v)
+	}
+	return values
+}
+
+func formatFloat(f float64) string {
+	b, _ := json.Marshal(f)
+	return string(b)
+}
+
+// pointString returns the string of values for the point at sec.
+func pointString(sec int, v string) string {
+	return formatFloat(ms(sec)) + ":" + v
+}
+
+func TestServeSpecs(t *testing.T) {
+	ts, _ := newTestServer(t)
+	defer ts.Close()
+	var graphs []graph
+	getJSON(t, ts.URL+"/api/specs", &graphs)
+	want := field{ID: "x", Name: "x", Counter: true}
+	if len(graphs) != 1 || len(graphs[0].Fields) != 1 || graphs[0].Fields[0] != want {
+		t.Errorf("specs = %+v, want a graph with %+v", graphs, want)
+	}
+}
+
+func TestServeData(t *testing.T) {
+	ts, _ := newTestServer(t)
+	defer ts.Close()
+	var d struct {
+		Size   int `json:"size"`
+		Graphs []struct {
+			Series []jsonSeries `json:"series"`
+		} `json:"graphs"`
+	}
+	getJSON(t, ts.URL+"/api/data", &d)
+	if d.Size != 100 || len(d.Graphs) != 1 || len(d.Graphs[0].Series) != 1 {
+		t.Fatalf("data = %+v, want a graph with a series", d)
+	}
+	got := strings.Join(d.Graphs[0].Series[0].values(), " ")
+	want := strings.Join([]string{pointString(0, "1"), pointString(1, "null"), pointString(2, "3")}, " ")
+	if got != want {
+		t.Errorf("points = %s, want %s", got, want)
+	}
+}
+
+func TestServeStream(t *testing.T) {
+	ts, ds := newTestServer(t)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/api/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	// a new point, a point updated in place and a late point
+	ds.Push("x", at(3), 4, data.Gauge)
+	ds.Push("x", at(2), 5, data.Gauge)
+	ds.Push("x", at(-1), 0, data.Gauge)
+
+	events := make(chan string, 100)
+	go func() {
+		r := bufio.NewReader(resp.Body)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(events)
+				return
+			}
+			if strings.HasPrefix(line, "data: ") {
+				events <- strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for len(got) < 3 {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatal("stream closed")
+			}
+			var updates []jsonSeries
+			if err := json.Unmarshal([]byte(e), &updates); err != nil {
+				t.Fatalf("event %q: %v", e, err)
+			}
+			for _, u := range updates {
+				if u.ID != "x" || u.Name != "x" {
+					t.Errorf("update of %q/%q, want x", u.ID, u.Name)
+				}
+				got = append(got, u.values()...)
+			}
+		case <-timeout:
+			t.Fatalf("updates = %v, want 3 points", got)
+		}
+	}
+	want := []string{pointString(-1, "0"), pointString(2, "5"), pointString(3, "4")}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("updates = %v, want %v", got, want)
+	}
+}
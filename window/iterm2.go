@@ -0,0 +1,24 @@
+package window
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+)
+
+// ITerm2 prints images using the iTerm2 inline image protocol.
+type ITerm2 struct{}
+
+func (ITerm2) Print(w io.Writer, img image.Image) error {
+	var b bytes.Buffer
+	enc := base64.NewEncoder(base64.StdEncoding, &b)
+	if err := png.Encode(enc, img); err != nil {
+		return err
+	}
+	enc.Close()
+	_, err := fmt.Fprintf(w, "\033]1337;File=preserveAspectRatio=1;inline=1:%s\007", b.Bytes())
+	return err
+}
@@ -0,0 +1,67 @@
+package window
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"io"
+	"strconv"
+)
+
+// kittyChunkSize is the maximum size of the base64 payload of a single
+// graphics escape sequence.
+const kittyChunkSize = 4096
+
+// Kitty prints images using the kitty graphics protocol. Every image is
+// transmitted with the same image and placement id so each frame replaces
+// the previous one instead of adding a new image to the scrollback.
+type Kitty struct {
+	// ID is the image id to use, 1 if not set.
+	ID int
+}
+
+func (k *Kitty) Print(w io.Writer, img image.Image) error {
+	var b bytes.Buffer
+	enc := base64.NewEncoder(base64.StdEncoding, &b)
+	if err := png.Encode(enc, img); err != nil {
+		return err
+	}
+	enc.Close()
+	id := k.ID
+	if id == 0 {
+		id = 1
+	}
+
+	var out bytes.Buffer
+	payload := b.Bytes()
+	first := true
+	for len(payload) > 0 {
+		n := len(payload)
+		if n > kittyChunkSize {
+			n = kittyChunkSize
+		}
+		chunk := payload[:n]
+		payload = payload[n:]
+		more := "0"
+		if len(payload) > 0 {
+			more = "1"
+		}
+		out.WriteString("\033_G")
+		if first {
+			// a=T: transmit and display, f=100: PNG, q=2: no response,
+			// C=1: do not move the cursor.
+			out.WriteString("a=T,f=100,q=2,C=1,i=")
+			out.WriteString(strconv.Itoa(id))
+			out.WriteString(",p=1,")
+			first = false
+		}
+		out.WriteString("m=")
+		out.WriteString(more)
+		out.WriteByte(';')
+		out.Write(chunk)
+		out.WriteString("\033\\")
+	}
+	_, err := w.Write(out.Bytes())
+	return err
+}
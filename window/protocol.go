@@ -0,0 +1,54 @@
+package window
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strings"
+)
+
+// Protocol prints an image inline in the terminal.
+type Protocol interface {
+	Print(w io.Writer, img image.Image) error
+}
+
+// Output is the protocol used by PrintGraphs.
+var Output Protocol = ITerm2{}
+
+// ProtocolByName returns the protocol matching name. The "auto" name selects
+// the protocol using DetectProtocol.
+func ProtocolByName(name string) (Protocol, error) {
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return DetectProtocol(), nil
+	case "iterm2", "iterm":
+		return ITerm2{}, nil
+	case "kitty":
+		return &Kitty{}, nil
+	case "sixel":
+		return Sixel{}, nil
+	default:
+		return nil, fmt.Errorf("unknown protocol: %s", name)
+	}
+}
+
+// DetectProtocol guesses the protocol supported by the terminal from the
+// TERM and TERM_PROGRAM environment variables. It defaults to iTerm2. Sixel is
+// only detected for terminals always built with sixel support; others, like
+// xterm or tmux, must select it explicitly.
+func DetectProtocol() Protocol {
+	term := os.Getenv("TERM")
+	termProgram := os.Getenv("TERM_PROGRAM")
+	switch {
+	case termProgram == "iTerm.app":
+		return ITerm2{}
+	case term == "xterm-kitty", os.Getenv("KITTY_WINDOW_ID") != "":
+		return &Kitty{}
+	case termProgram == "WezTerm", term == "wezterm":
+		return &Kitty{}
+	case strings.HasPrefix(term, "foot"), strings.HasPrefix(term, "mlterm"), strings.HasPrefix(term, "contour"):
+		return Sixel{}
+	}
+	return ITerm2{}
+}
@@ -1,13 +1,12 @@
 package window
 
 import (
-	"bytes"
-	"encoding/base64"
 	"fmt"
 	"image"
 	"image/draw"
-	"image/png"
 	"math"
+	"os"
+	"time"
 
 	humanize "github.com/dustin/go-humanize"
 	"github.com/rs/jplot/data"
@@ -16,6 +15,8 @@ import (
 	//"github.com/wcharczuk/go-chart/seq"
 )
 
+// Init styles graphs with a transparent background and light text, for dark
+// terminals and snapshots.
 func Init() {
 	chart.DefaultBackgroundColor = chart.ColorTransparent
 	chart.DefaultCanvasColor = chart.ColorTransparent
@@ -29,16 +30,44 @@ func Clear() {
 	print("\033]1337;CursorShape=1\007")  // set cursor to vertical bar
 }
 
+// Restore restores the cursor shape changed by Clear.
+func Restore() {
+	print("\033]1337;CursorShape=0\007") // set cursor to block
+}
+
 func Reset() {
 	print("\033\133\061\073\061\110") // move cursor to 0x0
 }
 
-// Graph generate a line graph with series.
+// envelope is the minimum or maximum of the buckets of a downsampled series,
+// drawn as a thin line around the average.
+type envelope struct {
+	chart.TimeSeries
+}
+
+// Graph generate a line graph with series. Time series without a name are
+// the segments of the previous series before a gap: they share its color and
+// have no last value annotation. Envelopes share the color of the previous
+// series too.
 func Graph(series []chart.Series, markers []chart.GridLine, width, height int) chart.Chart {
+	color := -1
 	for i, s := range series {
+		if e, ok := s.(envelope); ok {
+			c := chart.GetAlternateColor(color + 4)
+			e.Style = chart.Style{
+				Show:        true,
+				StrokeWidth: 1,
+				StrokeColor: c.WithAlpha(100),
+			}
+			series[i] = e
+			continue
+		}
 		if s, ok := s.(chart.TimeSeries); ok {
 			//s.XValues = seq.Range(0, float64(len(s.YValues)-1))
-			c := chart.GetAlternateColor(i + 4)
+			if s.Name != "" || color < 0 {
+				color++
+			}
+			c := chart.GetAlternateColor(color + 4)
 			s.Style = chart.Style{
 				Show:        true,
 				StrokeWidth: 2,
@@ -47,6 +76,9 @@ func Graph(series []chart.Series, markers []chart.GridLine, width, height int) c
 				FontSize:    9,
 			}
 			series[i] = s
+			if s.Name == "" {
+				continue
+			}
 			last := chart.LastValueAnnotation(s, SIValueFormater)
 			last.Style.FillColor = c
 			last.Style.FontColor = TextColor(c)
@@ -129,8 +161,17 @@ func SIValueFormater(v interface{}) string {
 	return humanize.Ftoa(value) + " " + prefix
 }
 
-// PrintGraphs generates a single PNG with graphs stacked and print it to iTerm2.
+// PrintGraphs generates a single image with graphs stacked and print it to
+// the terminal using the Output protocol, followed by the status line.
 func PrintGraphs(graphs []chart.Chart) {
+	Reset()
+	Output.Print(os.Stdout, Compose(graphs))
+	printStatus(os.Stdout)
+}
+
+// Compose renders graphs stacked in a single image. Graphs that cannot be
+// rendered, like graphs with no series to draw yet, are left blank.
+func Compose(graphs []chart.Chart) *image.RGBA {
 	var width, height int
 	for _, graph := range graphs {
 		if graph.Width > width {
@@ -138,47 +179,98 @@ func PrintGraphs(graphs []chart.Chart) {
 		}
 		height += graph.Height
 	}
-	Reset()
 	canvas := image.NewRGBA(image.Rectangle{image.Point{0, 0}, image.Point{width, height}})
 	var top int
 	for _, graph := range graphs {
 		iw := &chart.ImageWriter{}
-		graph.Render(chart.PNG, iw)
-		img, _ := iw.Image()
 		r := image.Rectangle{image.Point{0, top}, image.Point{width, top + graph.Height}}
 		top += graph.Height
+		if err := graph.Render(chart.PNG, iw); err != nil {
+			// no series to draw yet
+			continue
+		}
+		img, err := iw.Image()
+		if err != nil {
+			continue
+		}
 		draw.Draw(canvas, r, img, image.Point{0, 0}, draw.Src)
 	}
-	var b bytes.Buffer
-	enc := base64.NewEncoder(base64.StdEncoding, &b)
-	defer enc.Close()
-	png.Encode(enc, canvas)
-	fmt.Printf("\033]1337;File=preserveAspectRatio=1;inline=1:%s\007", b.Bytes())
+	return canvas
 }
 
+// Render draws specs as stacked graphs using the Output protocol.
 func Render(specs []data.GraphSpec, ds *data.DataSet, width, height int) {
+	PrintGraphs(Charts(specs, ds, width, height))
+}
+
+// Charts generates a graph per spec sharing height. When the DataSet has a
+// time Window, the X axis of all graphs shows that fixed time span ending at
+// the most recent point.
+func Charts(specs []data.GraphSpec, ds *data.DataSet, width, height int) []chart.Chart {
 	graphs := make([]chart.Chart, 0, len(specs))
+	var end time.Time
 	for _, gs := range specs {
 		series := []chart.Series{}
 		markers := []chart.GridLine{}
 		for _, f := range gs.Fields {
-			vals := ds.Get(f.ID)
-			if f.Marker {
-				for i, v := range vals {
-					if v.Value > 0 {
-						markers = append(markers, chart.GridLine{Value: float64(i)})
+			ids, names := ds.Series(f)
+			for k, id := range ids {
+				vals, min, max := ds.GetRange(id, ds.Window, width)
+				if len(vals) == 0 {
+					continue
+				}
+				if last := vals[len(vals)-1].Timestamp; last.After(end) {
+					end = last
+				}
+				if f.Marker {
+					for _, v := range vals {
+						if v.Value > 0 {
+							markers = append(markers, chart.GridLine{Value: timeToFloat(v.Timestamp)})
+						}
 					}
+					continue
+				}
+				// the last segment holds the name, the others follow it
+				segments := f.Reduce(vals, width).Segments()
+				for j := len(segments) - 1; j >= 0; j-- {
+					xVals, yVals := segments[j].XYValues()
+					var name string
+					if j == len(segments)-1 {
+						name = fmt.Sprintf("%s: %s", names[k], SIValueFormater(yVals[len(yVals)-1]))
+					}
+					series = append(series, chart.TimeSeries{
+						Name:    name,
+						XValues: xVals,
+						YValues: yVals,
+					})
+				}
+				// downsampled tiers have the min and max of their buckets
+				for _, env := range []data.Points{min, max} {
+					if len(env) == 0 {
+						continue
+					}
+					xVals, yVals := env.XYValues()
+					series = append(series, envelope{chart.TimeSeries{
+						XValues: xVals,
+						YValues: yVals,
+					}})
 				}
-				continue
 			}
-			xVals, yVals := vals.XYValues()
-			series = append(series, chart.TimeSeries{
-				Name:    fmt.Sprintf("%s: %s", f.Name, SIValueFormater(yVals[len(yVals)-1])),
-				XValues: xVals,
-				YValues: yVals,
-			})
 		}
 		graphs = append(graphs, Graph(series, markers, width, height/len(specs)))
 	}
-	PrintGraphs(graphs)
+	if ds.Window > 0 && !end.IsZero() {
+		for i := range graphs {
+			graphs[i].XAxis.Range = &chart.ContinuousRange{
+				Min: timeToFloat(end.Add(-ds.Window)),
+				Max: timeToFloat(end),
+			}
+		}
+	}
+	return graphs
+}
+
+// timeToFloat converts t to the X value used by chart.TimeSeries.
+func timeToFloat(t time.Time) float64 {
+	return float64(t.UnixNano())
 }
@@ -0,0 +1,65 @@
+package window
+
+import (
+	"os"
+
+	"github.com/rs/jplot/data"
+)
+
+// Renderer draws a frame of graphs sized to the current window.
+type Renderer interface {
+	Render(specs []data.GraphSpec, ds *data.DataSet) error
+}
+
+// NopRenderer renders nothing, for sessions without a terminal whose graphs
+// are only served over HTTP.
+type NopRenderer struct{}
+
+func (NopRenderer) Render(specs []data.GraphSpec, ds *data.DataSet) error {
+	return nil
+}
+
+// ImageRenderer renders graphs as an image printed with the Output protocol.
+type ImageRenderer struct{}
+
+func (ImageRenderer) Render(specs []data.GraphSpec, ds *data.DataSet) error {
+	width, height, err := Size()
+	if err != nil {
+		return err
+	}
+	Render(specs, ds, width, height-25)
+	return nil
+}
+
+// Clear clears the terminal before the first frame.
+func (ImageRenderer) Clear() {
+	Clear()
+}
+
+// Restore restores the terminal after the last frame.
+func (ImageRenderer) Restore() {
+	Restore()
+}
+
+// TextRenderer renders graphs as braille characters for terminals without
+// image support.
+type TextRenderer struct{}
+
+func (TextRenderer) Render(specs []data.GraphSpec, ds *data.DataSet) error {
+	cols, rows, err := CellCount()
+	if err != nil {
+		return err
+	}
+	// keep the last row for the cursor so the screen does not scroll
+	return RenderText(os.Stdout, specs, ds, cols, rows-1)
+}
+
+// Clear clears the terminal before the first frame.
+func (TextRenderer) Clear() {
+	Clear()
+}
+
+// Restore restores the terminal after the last frame.
+func (TextRenderer) Restore() {
+	Restore()
+}
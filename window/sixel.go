@@ -0,0 +1,168 @@
+package window
+
+import (
+	"bufio"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// sixelMaxColors is the number of color registers used by the encoder. Most
+// sixel capable terminals support at least 256 registers.
+const sixelMaxColors = 255
+
+// Sixel prints images using the DEC sixel graphics format. The image is
+// quantized to an adaptive palette of up to 255 colors. Fully transparent
+// pixels are left untouched so the terminal background shows through.
+type Sixel struct{}
+
+func (Sixel) Print(w io.Writer, img image.Image) error {
+	bw := bufio.NewWriter(w)
+	pal, idx := quantize(img)
+	encodeSixel(bw, img.Bounds(), pal, idx)
+	return bw.Flush()
+}
+
+// quantize reduces img to a palette. It returns the palette and, for each
+// pixel in row order, the palette index or -1 for transparent pixels.
+func quantize(img image.Image) (color.Palette, []int) {
+	b := img.Bounds()
+	pixels := make([]color.NRGBA, 0, b.Dx()*b.Dy())
+	counts := map[uint16]int{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			pixels = append(pixels, c)
+			if c.A >= 128 {
+				counts[bucket(c)]++
+			}
+		}
+	}
+
+	// Keep the most used 4 bit per channel buckets as the palette.
+	buckets := make([]uint16, 0, len(counts))
+	for k := range counts {
+		buckets = append(buckets, k)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if counts[buckets[i]] != counts[buckets[j]] {
+			return counts[buckets[i]] > counts[buckets[j]]
+		}
+		return buckets[i] < buckets[j]
+	})
+	if len(buckets) > sixelMaxColors {
+		buckets = buckets[:sixelMaxColors]
+	}
+	pal := make(color.Palette, 0, len(buckets))
+	for _, k := range buckets {
+		pal = append(pal, color.NRGBA{
+			R: uint8(k>>8&0xf) * 0x11,
+			G: uint8(k>>4&0xf) * 0x11,
+			B: uint8(k&0xf) * 0x11,
+			A: 0xff,
+		})
+	}
+
+	idx := make([]int, len(pixels))
+	cache := map[uint16]int{}
+	for i, c := range pixels {
+		if c.A < 128 || len(pal) == 0 {
+			idx[i] = -1
+			continue
+		}
+		k := bucket(c)
+		n, found := cache[k]
+		if !found {
+			n = pal.Index(color.NRGBA{R: c.R, G: c.G, B: c.B, A: 0xff})
+			cache[k] = n
+		}
+		idx[i] = n
+	}
+	return pal, idx
+}
+
+func bucket(c color.NRGBA) uint16 {
+	return uint16(c.R>>4)<<8 | uint16(c.G>>4)<<4 | uint16(c.B>>4)
+}
+
+// encodeSixel writes the DECSIXEL stream for the indexed pixels.
+func encodeSixel(w *bufio.Writer, b image.Rectangle, pal color.Palette, idx []int) {
+	width, height := b.Dx(), b.Dy()
+
+	// P2=1: pixels with no color stay transparent.
+	w.WriteString("\033P0;1;0q")
+	w.WriteString("\"1;1;" + strconv.Itoa(width) + ";" + strconv.Itoa(height))
+	for i, c := range pal {
+		r, g, bl, _ := c.RGBA()
+		w.WriteString("#" + strconv.Itoa(i) + ";2;" +
+			strconv.Itoa(int(r*100/0xffff)) + ";" +
+			strconv.Itoa(int(g*100/0xffff)) + ";" +
+			strconv.Itoa(int(bl*100/0xffff)))
+	}
+
+	sixels := make([]byte, width)
+	for top := 0; top < height; top += 6 {
+		// Colors used in this band, in palette order.
+		used := make([]bool, len(pal))
+		for y := top; y < top+6 && y < height; y++ {
+			for x := 0; x < width; x++ {
+				if n := idx[y*width+x]; n >= 0 {
+					used[n] = true
+				}
+			}
+		}
+		first := true
+		for n := range pal {
+			if !used[n] {
+				continue
+			}
+			for x := 0; x < width; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && top+dy < height; dy++ {
+					if idx[(top+dy)*width+x] == n {
+						bits |= 1 << uint(dy)
+					}
+				}
+				sixels[x] = 63 + bits
+			}
+			if !first {
+				w.WriteByte('$') // carriage return to the start of the band
+			}
+			first = false
+			w.WriteString("#" + strconv.Itoa(n))
+			writeSixelRun(w, sixels)
+		}
+		if top+6 < height {
+			// next band, not after the last one which would move the
+			// cursor below the image
+			w.WriteByte('-')
+		}
+	}
+	w.WriteString("\033\\")
+}
+
+// writeSixelRun writes sixels using run length encoding. Trailing empty
+// sixels are omitted.
+func writeSixelRun(w *bufio.Writer, sixels []byte) {
+	end := len(sixels)
+	for end > 0 && sixels[end-1] == 63 {
+		end--
+	}
+	for i := 0; i < end; {
+		j := i + 1
+		for j < end && sixels[j] == sixels[i] {
+			j++
+		}
+		if n := j - i; n > 3 {
+			w.WriteString("!" + strconv.Itoa(n))
+			w.WriteByte(sixels[i])
+		} else {
+			for k := 0; k < n; k++ {
+				w.WriteByte(sixels[i])
+			}
+		}
+		i = j
+	}
+}
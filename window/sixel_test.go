@@ -0,0 +1,97 @@
+package window
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestSixel(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{"bands", func() image.Image {
+			// two bands, the second one partial
+			img := image.NewRGBA(image.Rect(0, 0, 10, 8))
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 10; x++ {
+					c := color.RGBA{R: 255, A: 255}
+					if x >= y {
+						c = color.RGBA{B: 255, A: 255}
+					}
+					img.Set(x, y, c)
+				}
+			}
+			return img
+		}()},
+		{"transparent", func() image.Image {
+			// a diagonal line on a transparent background
+			img := image.NewRGBA(image.Rect(0, 0, 6, 6))
+			for i := 0; i < 6; i++ {
+				img.Set(i, i, color.RGBA{G: 255, A: 255})
+			}
+			return img
+		}()},
+		{"empty", image.NewRGBA(image.Rect(0, 0, 4, 4))},
+		{"colors", func() image.Image {
+			// 512 distinct colors, more than the palette holds
+			img := image.NewRGBA(image.Rect(0, 0, 32, 16))
+			for y := 0; y < 16; y++ {
+				for x := 0; x < 32; x++ {
+					img.Set(x, y, color.RGBA{R: uint8(x * 8), G: uint8(y * 16), B: uint8(x * y), A: 255})
+				}
+			}
+			return img
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := (Sixel{}).Print(buf, tt.img); err != nil {
+				t.Fatal(err)
+			}
+			got := buf.Bytes()
+			if !bytes.HasPrefix(got, []byte("\033P")) || !bytes.HasSuffix(got, []byte("\033\\")) {
+				t.Fatalf("output is not a DCS sequence: %q", got)
+			}
+			if bytes.HasSuffix(got, []byte("-\033\\")) {
+				t.Errorf("output ends with a new band: %q", got)
+			}
+			golden := filepath.Join("testdata", tt.name+".six")
+			if *update {
+				if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("output differs from %s:\ngot  %q\nwant %q", golden, got, want)
+			}
+		})
+	}
+}
+
+func TestQuantizeMaxColors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 8), G: uint8(y * 16), A: 255})
+		}
+	}
+	pal, idx := quantize(img)
+	if len(pal) != sixelMaxColors {
+		t.Errorf("palette has %d colors, want %d", len(pal), sixelMaxColors)
+	}
+	for i, n := range idx {
+		if n < 0 || n >= len(pal) {
+			t.Fatalf("pixel %d has index %d, want a palette color", i, n)
+		}
+	}
+}
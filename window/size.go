@@ -2,12 +2,170 @@ package window
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"os/signal"
 	"strconv"
 	"strings"
 )
 
+// SizeProvider returns the width and height in pixels of the area jplot can
+// draw into.
+type SizeProvider interface {
+	Size() (width, height int, err error)
+}
+
+// Providers is the list of size providers queried in order by Size. The first
+// one to succeed wins.
+var Providers = DefaultProviders(DefaultCellWidth, DefaultCellHeight)
+
+// Default size of a terminal cell in pixels used by the Cells provider.
+const (
+	DefaultCellWidth  = 8
+	DefaultCellHeight = 16
+)
+
+// DefaultProviders returns the provider chain used when no explicit size is
+// given: the pixel size reported by the terminal driver, the CSI 14 t escape
+// reply, iTerm2 window bounds and finally the cell count times the cell size.
+func DefaultProviders(cellWidth, cellHeight int) []SizeProvider {
+	return []SizeProvider{
+		Ioctl{},
+		&Escape{},
+		&ITerm{},
+		Cells{CellWidth: cellWidth, CellHeight: cellHeight},
+	}
+}
+
+// Size returns current window width and height.
+func Size() (int, int, error) {
+	var errs []string
+	for _, p := range Providers {
+		width, height, err := p.Size()
+		if err == nil && width > 0 && height > 0 {
+			return width, height, nil
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) == 0 {
+		return 0, 0, errors.New("no size provider")
+	}
+	return 0, 0, errors.New(strings.Join(errs, ", "))
+}
+
+// Fixed is a size provider returning a static size. It is used when the
+// size is explicitly given by the user.
+type Fixed struct {
+	Width, Height int
+}
+
+func (f Fixed) Size() (int, int, error) {
+	return f.Width, f.Height, nil
+}
+
+// Ioctl returns the pixel size reported by the terminal driver through the
+// TIOCGWINSZ ioctl. Many terminals leave the pixel fields empty, in which
+// case an error is returned.
+type Ioctl struct{}
+
+func (Ioctl) Size() (int, int, error) {
+	ws, err := getWinsize()
+	if err != nil {
+		return 0, 0, err
+	}
+	if ws.xpixel == 0 || ws.ypixel == 0 {
+		return 0, 0, errors.New("ioctl: pixel size not reported")
+	}
+	return int(ws.xpixel), int(ws.ypixel), nil
+}
+
+// Escape asks the terminal for the size of its text area with the CSI 14 t
+// escape sequence. The size is cached and the terminal is asked again only
+// once resized, to avoid a round-trip to the terminal on each frame. Once the
+// terminal failed to reply, it is not asked again.
+type Escape struct {
+	unsupported   bool
+	width, height int
+	resized       chan os.Signal
+}
+
+func (e *Escape) Size() (int, int, error) {
+	if e.unsupported {
+		return 0, 0, errors.New("escape: not supported by terminal")
+	}
+	if e.resized == nil {
+		e.resized = make(chan os.Signal, 1)
+		if len(resizeSignals) > 0 {
+			signal.Notify(e.resized, resizeSignals...)
+		}
+	}
+	select {
+	case <-e.resized:
+	default:
+		if e.width > 0 && e.height > 0 {
+			return e.width, e.height, nil
+		}
+	}
+	width, height, err := queryPixelSize()
+	if err != nil {
+		e.unsupported = true
+		return 0, 0, fmt.Errorf("escape: %v", err)
+	}
+	e.width, e.height = width, height
+	return width, height, nil
+}
+
+// parsePixelSizeReply parses a CSI 4 ; height ; width t reply.
+func parsePixelSizeReply(b []byte) (int, int, error) {
+	i := bytes.Index(b, []byte("\033[4;"))
+	if i == -1 || !bytes.HasSuffix(b, []byte("t")) {
+		return 0, 0, fmt.Errorf("invalid reply: %q", b)
+	}
+	rv := strings.Split(string(b[i+4:len(b)-1]), ";")
+	if len(rv) != 2 {
+		return 0, 0, fmt.Errorf("invalid reply: %q", b)
+	}
+	height, err := strconv.Atoi(rv[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid reply: %q", b)
+	}
+	width, err := strconv.Atoi(rv[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid reply: %q", b)
+	}
+	return width, height, nil
+}
+
+// Cells computes the size from the number of rows and columns of the
+// terminal multiplied by the size of a cell.
+type Cells struct {
+	CellWidth, CellHeight int
+}
+
+func (c Cells) Size() (int, int, error) {
+	cols, rows, err := CellCount()
+	if err != nil {
+		return 0, 0, err
+	}
+	return cols * c.CellWidth, rows * c.CellHeight, nil
+}
+
+// CellCount returns the number of columns and rows of the terminal.
+func CellCount() (int, int, error) {
+	ws, err := getWinsize()
+	if err != nil {
+		return 0, 0, err
+	}
+	if ws.col == 0 || ws.row == 0 {
+		return 0, 0, errors.New("ioctl: cell count not reported")
+	}
+	return int(ws.col), int(ws.row), nil
+}
+
 // Gets the size of the current front
 var script = `
 tell application "iTerm"
@@ -20,13 +178,16 @@ tell application "iTerm"
 	do shell script "echo " & (id of w) & " " & width & " " & height
 end tell`
 
-var winID string
+// ITerm asks iTerm2 for the bounds of its front window using osascript. The
+// window is then tracked by id so the size stays stable if focus changes.
+type ITerm struct {
+	winID string
+}
 
-// Size returns current window height and width.
-func Size() (int, int, error) {
+func (it *ITerm) Size() (int, int, error) {
 	win := "front window"
-	if winID != "" {
-		win = "window id " + winID
+	if it.winID != "" {
+		win = "window id " + it.winID
 	}
 	cmd := exec.Command("osascript", "-e", fmt.Sprintf(script, win))
 	cmd.Stdin = strings.NewReader("some input")
@@ -39,7 +200,7 @@ func Size() (int, int, error) {
 	if len(rv) != 3 {
 		return 0, 0, fmt.Errorf("invalid output: %s", out.String())
 	}
-	winID = rv[0]
+	it.winID = rv[0]
 	width, _ := strconv.Atoi(rv[1])
 	height, _ := strconv.Atoi(rv[2])
 	return width, height, nil
@@ -0,0 +1,124 @@
+package window
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/jplot/data"
+	"github.com/wcharczuk/go-chart"
+)
+
+// Size of snapshots when none is given.
+const (
+	DefaultSnapshotWidth  = 1200
+	DefaultSnapshotHeight = 800
+)
+
+// SnapshotBackground is the color behind the graphs of snapshots, which are
+// transparent and styled for a dark terminal once Init is called.
+var SnapshotBackground = color.RGBA{R: 30, G: 30, B: 30, A: 255}
+
+// SnapshotRenderer writes graphs to image files in Dir instead of printing
+// them to the terminal.
+type SnapshotRenderer struct {
+	Dir string
+	// Format is the format of the files, png or svg.
+	Format        string
+	Width, Height int
+	// Every is the minimum time between two snapshots written by Render.
+	Every time.Duration
+
+	next time.Time
+}
+
+// Render writes a snapshot, unless the previous one was written less than
+// Every ago.
+func (s *SnapshotRenderer) Render(specs []data.GraphSpec, ds *data.DataSet) error {
+	now := time.Now()
+	if now.Before(s.next) {
+		return nil
+	}
+	s.next = now.Add(s.Every)
+	return s.Snapshot(specs, ds)
+}
+
+// RenderLast writes a snapshot of the last frame, however recent the previous
+// one is.
+func (s *SnapshotRenderer) RenderLast(specs []data.GraphSpec, ds *data.DataSet) error {
+	return s.Snapshot(specs, ds)
+}
+
+// Snapshot writes the graphs of specs to a new file in Dir named after the
+// current time.
+func (s *SnapshotRenderer) Snapshot(specs []data.GraphSpec, ds *data.DataSet) error {
+	format := s.Format
+	if format == "" {
+		format = "png"
+	}
+	graphs := Charts(specs, ds, s.Width, s.Height)
+	buf := &bytes.Buffer{}
+	switch format {
+	case "png":
+		canvas := image.NewRGBA(image.Rect(0, 0, s.Width, s.Height))
+		draw.Draw(canvas, canvas.Bounds(), image.NewUniform(SnapshotBackground), image.Point{}, draw.Src)
+		img := Compose(graphs)
+		draw.Draw(canvas, img.Bounds(), img, image.Point{}, draw.Over)
+		if err := png.Encode(buf, canvas); err != nil {
+			return err
+		}
+	case "svg":
+		if err := WriteSVG(buf, graphs); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported snapshot format: %s", format)
+	}
+	name := fmt.Sprintf("jplot-%s.%s", time.Now().Format("20060102-150405"), format)
+	f, err := os.Create(filepath.Join(s.Dir, name))
+	if err != nil {
+		return err
+	}
+	if _, err := buf.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteSVG writes graphs stacked in a single SVG document. Graphs that cannot
+// be rendered, like graphs with no series to draw yet, are left blank.
+func WriteSVG(w io.Writer, graphs []chart.Chart) error {
+	var width, height int
+	for _, graph := range graphs {
+		if graph.Width > width {
+			width = graph.Width
+		}
+		height += graph.Height
+	}
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`+"\n", width, height)
+	c := SnapshotBackground
+	fmt.Fprintf(buf, `<rect width="100%%" height="100%%" fill="rgb(%d,%d,%d)"/>`+"\n", c.R, c.G, c.B)
+	var top int
+	for _, graph := range graphs {
+		fmt.Fprintf(buf, `<g transform="translate(0,%d)">`+"\n", top)
+		top += graph.Height
+		// render separately as a failing graph may write a partial document
+		g := &bytes.Buffer{}
+		if err := graph.Render(chart.SVG, g); err == nil {
+			g.WriteTo(buf)
+		}
+		buf.WriteString("</g>\n")
+	}
+	buf.WriteString("</svg>\n")
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,88 @@
+package window
+
+import (
+	"flag"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/jplot/data"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+func TestSnapshot(t *testing.T) {
+	Init()
+	// the time labels of the X axis are in the local time zone
+	local := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = local }()
+	ds := &data.DataSet{Size: 100}
+	start := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+	for i := 0; i < 60; i++ {
+		ts := start.Add(time.Duration(i) * time.Second)
+		ds.Push("sin", ts, 10*math.Sin(float64(i)/10), data.Gauge)
+		if i < 20 || i >= 30 {
+			ds.Push("count", ts, float64(i), data.Gauge)
+		} else {
+			ds.PushGap("count", ts)
+		}
+	}
+	specs := []data.GraphSpec{
+		{Fields: []data.Field{{ID: "sin", Name: "sin"}}},
+		{Fields: []data.Field{{ID: "count", Name: "count"}}},
+	}
+	dir, err := ioutil.TempDir("", "jplot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := &SnapshotRenderer{Dir: dir, Format: "png", Width: 400, Height: 300}
+	if err := s.Snapshot(specs, ds); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "jplot-*.png"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("snapshot files = %v, %v, want a single png", files, err)
+	}
+	golden := filepath.Join("testdata", "snapshot.png")
+	if *update {
+		b, err := ioutil.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(golden, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, want := readPNG(t, files[0]), readPNG(t, golden)
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("snapshot size = %v, want %v", got.Bounds(), want.Bounds())
+	}
+	b := got.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if got.At(x, y) != want.At(x, y) {
+				t.Fatalf("snapshot differs from %s at %d,%d: %v, want %v", golden, x, y, got.At(x, y), want.At(x, y))
+			}
+		}
+	}
+}
+
+func readPNG(t *testing.T, name string) image.Image {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
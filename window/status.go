@@ -0,0 +1,34 @@
+package window
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+var status struct {
+	sync.Mutex
+	msg string
+}
+
+// SetStatus sets the message shown on the last row of the terminal below the
+// graphs, like a fetch error. An empty msg clears the status line.
+func SetStatus(msg string) {
+	status.Lock()
+	status.msg = msg
+	status.Unlock()
+}
+
+// printStatus writes the status line to the last row of the terminal, leaving
+// the cursor where it was.
+func printStatus(w io.Writer) {
+	status.Lock()
+	msg := status.msg
+	status.Unlock()
+	// save cursor, move to the last row and clear it
+	fmt.Fprint(w, "\0337\033[999;1H\033[2K")
+	if msg != "" {
+		fmt.Fprintf(w, "\033[31m%s\033[0m", msg)
+	}
+	fmt.Fprint(w, "\0338") // restore cursor
+}
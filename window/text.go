@@ -0,0 +1,303 @@
+package window
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/jplot/data"
+)
+
+// textColors are the ANSI SGR foreground colors used for series.
+var textColors = []string{"32", "33", "34", "35", "36", "31", "92", "93", "94", "95", "96", "91"}
+
+// textMarkerColor is the SGR color of marker bars.
+const textMarkerColor = "90"
+
+// textAxisWidth is the number of columns reserved for the Y axis labels.
+const textAxisWidth = 9
+
+// brailleBits maps a dot position within a cell (x in 0-1, y in 0-3) to its
+// bit in the braille pattern.
+var brailleBits = [2][4]rune{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+// brailleCanvas is a grid of braille cells, each holding 2x4 dots.
+type brailleCanvas struct {
+	cols, rows int
+	cells      []rune
+	colors     []string
+}
+
+func newBrailleCanvas(cols, rows int) *brailleCanvas {
+	return &brailleCanvas{
+		cols:   cols,
+		rows:   rows,
+		cells:  make([]rune, cols*rows),
+		colors: make([]string, cols*rows),
+	}
+}
+
+// set turns on the dot at x, y, with 0, 0 being the top left dot.
+func (c *brailleCanvas) set(x, y int, color string) {
+	if x < 0 || y < 0 || x >= c.cols*2 || y >= c.rows*4 {
+		return
+	}
+	i := (y/4)*c.cols + x/2
+	c.cells[i] |= brailleBits[x%2][y%4]
+	c.colors[i] = color
+}
+
+// line draws a line between two dots using Bresenham's algorithm.
+func (c *brailleCanvas) line(x0, y0, x1, y1 int, color string) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		c.set(x0, y0, color)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * err; e2 >= dy {
+			err += dy
+			x0 += sx
+		} else {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// row returns the colored text of a row of cells.
+func (c *brailleCanvas) row(y int) string {
+	var b bytes.Buffer
+	color := ""
+	for x := 0; x < c.cols; x++ {
+		i := y*c.cols + x
+		if c.colors[i] != color {
+			if c.colors[i] == "" {
+				b.WriteString("\033[0m")
+			} else {
+				b.WriteString("\033[" + c.colors[i] + "m")
+			}
+			color = c.colors[i]
+		}
+		b.WriteRune(0x2800 + c.cells[i])
+	}
+	if color != "" {
+		b.WriteString("\033[0m")
+	}
+	return b.String()
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// RenderText draws each graph spec as a braille line chart on w, using at
+// most cols columns and rows rows of text. The status line is drawn on the
+// last row of the terminal.
+func RenderText(w io.Writer, specs []data.GraphSpec, ds *data.DataSet, cols, rows int) error {
+	if len(specs) == 0 {
+		return nil
+	}
+	var b bytes.Buffer
+	b.WriteString("\033[H") // move cursor to 0x0
+	graphRows := rows / len(specs)
+	var start time.Time
+	if ds.Window > 0 {
+		start = latest(specs, ds).Add(-ds.Window)
+	}
+	for _, gs := range specs {
+		for _, line := range textGraph(gs, ds, start, cols, graphRows) {
+			b.WriteString(line)
+			b.WriteString("\033[K\n") // clear the end of the line
+		}
+	}
+	b.WriteString("\033[J") // clear the rest of the screen
+	printStatus(&b)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// latest returns the timestamp of the most recent point of specs.
+func latest(specs []data.GraphSpec, ds *data.DataSet) time.Time {
+	var end time.Time
+	for _, gs := range specs {
+		for _, f := range gs.Fields {
+			ids, _ := ds.Series(f)
+			for _, id := range ids {
+				if vals := ds.Get(id); len(vals) > 0 && vals[len(vals)-1].Timestamp.After(end) {
+					end = vals[len(vals)-1].Timestamp
+				}
+			}
+		}
+	}
+	return end
+}
+
+// textGraph returns the lines of a single graph: a legend line followed by
+// the chart with its Y axis. If start is not zero, the X axis starts at start
+// instead of the oldest point.
+func textGraph(gs data.GraphSpec, ds *data.DataSet, start time.Time, cols, rows int) []string {
+	plotCols := cols - textAxisWidth - 1
+	plotRows := rows - 1
+	if plotCols < 1 || plotRows < 1 {
+		return nil
+	}
+
+	type series struct {
+		name     string
+		color    string
+		points   data.Points
+		min, max data.Points
+	}
+	var lines, markers []series
+	var minT, maxT time.Time
+	minV, maxV := math.Inf(1), math.Inf(-1)
+	for _, f := range gs.Fields {
+		ids, names := ds.Series(f)
+		for i, id := range ids {
+			vals, min, max := ds.GetRange(id, ds.Window, plotCols*2)
+			if len(vals) == 0 {
+				continue
+			}
+			if minT.IsZero() || vals[0].Timestamp.Before(minT) {
+				minT = vals[0].Timestamp
+			}
+			if last := vals[len(vals)-1].Timestamp; last.After(maxT) {
+				maxT = last
+			}
+			if f.Marker {
+				markers = append(markers, series{points: vals})
+				continue
+			}
+			var available bool
+			for _, p := range vals {
+				if p.IsGap() {
+					continue
+				}
+				available = true
+				minV = math.Min(minV, p.Value)
+				maxV = math.Max(maxV, p.Value)
+			}
+			if !available {
+				continue
+			}
+			for _, p := range min {
+				minV = math.Min(minV, p.Value)
+			}
+			for _, p := range max {
+				maxV = math.Max(maxV, p.Value)
+			}
+			lines = append(lines, series{
+				name:   names[i],
+				color:  textColors[len(lines)%len(textColors)],
+				points: f.Reduce(vals, plotCols*2),
+				min:    min,
+				max:    max,
+			})
+		}
+	}
+	if !start.IsZero() {
+		minT = start
+	}
+	if math.IsInf(minV, 0) {
+		minV, maxV = 0, 0
+	}
+	if minV == maxV {
+		minV, maxV = minV-1, maxV+1
+	}
+	span := maxT.Sub(minT)
+
+	canvas := newBrailleCanvas(plotCols, plotRows)
+	width, height := plotCols*2, plotRows*4
+	xPos := func(t time.Time) int {
+		if span <= 0 {
+			return width - 1
+		}
+		return int(float64(t.Sub(minT)) / float64(span) * float64(width-1))
+	}
+	yPos := func(v float64) int {
+		return height - 1 - int((v-minV)/(maxV-minV)*float64(height-1))
+	}
+	for _, m := range markers {
+		for _, p := range m.points {
+			if p.Value > 0 {
+				x := xPos(p.Timestamp)
+				canvas.line(x, 0, x, height-1, textMarkerColor)
+			}
+		}
+	}
+	// the envelope of downsampled series spans the min and max of buckets
+	for _, s := range lines {
+		for j := range s.min {
+			if j < len(s.max) {
+				x := xPos(s.min[j].Timestamp)
+				canvas.line(x, yPos(s.min[j].Value), x, yPos(s.max[j].Value), s.color)
+			}
+		}
+	}
+	for _, s := range lines {
+		px, py := -1, -1
+		for _, p := range s.points {
+			if p.IsGap() {
+				px, py = -1, -1
+				continue
+			}
+			x, y := xPos(p.Timestamp), yPos(p.Value)
+			if px == -1 {
+				canvas.set(x, y, s.color)
+			} else {
+				canvas.line(px, py, x, y, s.color)
+			}
+			px, py = x, y
+		}
+	}
+
+	out := make([]string, 0, rows)
+	var legend bytes.Buffer
+	for i, s := range lines {
+		if i > 0 {
+			legend.WriteString("  ")
+		}
+		last := math.NaN()
+		for j := len(s.points) - 1; j >= 0 && math.IsNaN(last); j-- {
+			last = s.points[j].Value
+		}
+		fmt.Fprintf(&legend, "\033[%sm■\033[0m %s: %s", s.color, s.name, strings.TrimSpace(SIValueFormater(last)))
+	}
+	out = append(out, strings.Repeat(" ", textAxisWidth+1)+legend.String())
+	for y := 0; y < plotRows; y++ {
+		var label string
+		switch y {
+		case 0:
+			label = SIValueFormater(maxV)
+		case plotRows - 1:
+			label = SIValueFormater(minV)
+		case plotRows / 2:
+			label = SIValueFormater((maxV + minV) / 2)
+		}
+		label = strings.TrimSpace(label)
+		if n := utf8.RuneCountInString(label); n < textAxisWidth {
+			label = strings.Repeat(" ", textAxisWidth-n) + label
+		}
+		out = append(out, label+"┤"+canvas.row(y))
+	}
+	return out
+}
@@ -0,0 +1,27 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package window
+
+import (
+	"errors"
+	"os"
+)
+
+// resizeSignals are the signals sent when the terminal is resized.
+var resizeSignals []os.Signal
+
+type winsize struct {
+	row, col       uint16
+	xpixel, ypixel uint16
+}
+
+var errNoTTY = errors.New("terminal size not supported on this platform")
+
+func getWinsize() (winsize, error) {
+	return winsize{}, errNoTTY
+}
+
+func queryPixelSize() (int, int, error) {
+	return 0, 0, errNoTTY
+}
@@ -0,0 +1,95 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package window
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// resizeSignals are the signals sent when the terminal is resized.
+var resizeSignals = []os.Signal{syscall.SIGWINCH}
+
+type winsize struct {
+	row, col       uint16
+	xpixel, ypixel uint16
+}
+
+func getWinsize() (winsize, error) {
+	var ws winsize
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return ws, err
+	}
+	defer tty.Close()
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, tty.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 {
+		return ws, errno
+	}
+	return ws, nil
+}
+
+func getTermios(fd uintptr) (syscall.Termios, error) {
+	var t syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlGetTermios, uintptr(unsafe.Pointer(&t)))
+	if errno != 0 {
+		return t, errno
+	}
+	return t, nil
+}
+
+func setTermios(fd uintptr, t syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlSetTermios, uintptr(unsafe.Pointer(&t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// queryPixelSize sends CSI 14 t to the terminal and reads the reply with the
+// terminal temporarily put in non-canonical mode.
+func queryPixelSize() (int, int, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tty.Close()
+	fd := tty.Fd()
+	old, err := getTermios(fd)
+	if err != nil {
+		return 0, 0, err
+	}
+	raw := old
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO
+	raw.Cc[syscall.VMIN] = 0
+	raw.Cc[syscall.VTIME] = 1 // read returns after 100ms without input
+	if err := setTermios(fd, raw); err != nil {
+		return 0, 0, err
+	}
+	defer setTermios(fd, old)
+
+	if _, err := tty.Write([]byte("\033[14t")); err != nil {
+		return 0, 0, err
+	}
+	var reply []byte
+	buf := make([]byte, 32)
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		n, err := tty.Read(buf)
+		if err != nil {
+			return 0, 0, err
+		}
+		if n == 0 {
+			continue
+		}
+		reply = append(reply, buf[:n]...)
+		if reply[len(reply)-1] == 't' {
+			return parsePixelSizeReply(reply)
+		}
+	}
+	return 0, 0, errors.New("no reply from terminal")
+}